@@ -0,0 +1,204 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"golang.org/x/playground/internal/snippets"
+)
+
+// The admin API lets the playground's maintainers take down snippets,
+// such as abusive ones or ones with leaked credentials. It is only
+// served if -admin-keys-file is set.
+//
+// POST /admin/remove with {"ID": ..., "Action": ..., "Reason": ...}
+// leaves a tombstone for the snippet ID, so that it's no longer served
+// and can't be shared again. The "delete" action discards its body;
+// "block" keeps it. Either works for an ID that hasn't been shared.
+// With "Mutable": true, ID is that of a mutable snippet, which is
+// no longer served or updated, and its current revision is removed.
+// GET /admin/removals returns the audit log of removals, newest first.
+
+// minAdminKeyLen is the minimum length in bytes of an admin's key.
+const minAdminKeyLen = 32
+
+// loadAdminKeys reads the named file of admin keys. Each line holds
+// an admin's name and key, separated by white space. Blank lines and
+// lines starting with # are ignored. It returns a map of names to keys.
+func loadAdminKeys(name string) (map[string][]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string][]byte)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) != 2 {
+			return nil, fmt.Errorf("%s:%d: want an admin name and key", name, n)
+		}
+		if len(f[1]) < minAdminKeyLen {
+			return nil, fmt.Errorf("%s:%d: key is %d bytes; want at least %d", name, n, len(f[1]), minAdminKeyLen)
+		}
+		if _, ok := keys[f[0]]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate admin %q", name, n, f[0])
+		}
+		keys[f[0]] = []byte(f[1])
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s: no admin keys", name)
+	}
+	return keys, nil
+}
+
+// adminName returns the name of the admin whose key r carries as a
+// bearer token, if any.
+func (s *server) adminName(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "", false
+	}
+	admin := ""
+	for name, key := range s.adminKeys {
+		// Compare with every key, so that the time taken doesn't
+		// depend on which one matches.
+		if subtle.ConstantTimeCompare([]byte(token), key) == 1 {
+			admin = name
+		}
+	}
+	return admin, admin != ""
+}
+
+// authAdmin returns the name of the admin making r. If r isn't from
+// an admin, it replies with an error and returns false.
+func (s *server) authAdmin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if s.adminKeys == nil {
+		http.NotFound(w, r)
+		return "", false
+	}
+	admin, ok := s.adminName(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return "", false
+	}
+	return admin, true
+}
+
+// removeRequest is the JSON body of a POST to /admin/remove.
+type removeRequest struct {
+	ID     string
+	Action string // snippets.Delete or snippets.Block
+	Reason string
+	// Mutable is whether ID is of a mutable snippet, served at /m/ID.
+	Mutable bool
+}
+
+func (s *server) handleAdminRemove(w http.ResponseWriter, r *http.Request) {
+	admin, ok := s.authAdmin(w, r)
+	if !ok {
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Requires POST", http.StatusMethodNotAllowed)
+		return
+	}
+	var req removeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch {
+	case !snippets.ValidID(req.ID):
+		http.Error(w, "Invalid snippet ID", http.StatusBadRequest)
+		return
+	case req.Action != snippets.Delete && req.Action != snippets.Block:
+		http.Error(w, `Action must be "delete" or "block"`, http.StatusBadRequest)
+		return
+	case strings.TrimSpace(req.Reason) == "":
+		http.Error(w, "A Reason is required", http.StatusBadRequest)
+		return
+	}
+
+	rm := &snippets.Removal{ID: req.ID, Action: req.Action, Admin: admin, Reason: req.Reason, Time: time.Now().UTC()}
+	if req.Mutable {
+		// Stop the mutable snippet being served or updated first,
+		// so that its current revision stays current.
+		err := s.db.UpdateMutable(r.Context(), req.ID, func(m *snippets.Mutable) error {
+			if m.Head == "" {
+				return datastore.ErrNoSuchEntity
+			}
+			if m.Removed.IsZero() {
+				m.Removed = rm.Time
+			}
+			rm.ID, rm.Mutable = m.Head, req.ID
+			return nil
+		})
+		if err == datastore.ErrNoSuchEntity {
+			http.Error(w, "Snippet not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			s.log.Errorf("removing mutable Snippet %s: %v", req.ID, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := s.db.RemoveSnippet(r.Context(), rm.ID, rm); err != nil {
+		s.log.Errorf("removing Snippet %s: %v", rm.ID, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if req.Mutable {
+		s.log.Printf("admin %s removed mutable snippet %s, at revision %s (%s): %s", admin, req.ID, rm.ID, req.Action, req.Reason)
+	} else {
+		s.log.Printf("admin %s removed snippet %s (%s): %s", admin, req.ID, req.Action, req.Reason)
+	}
+	s.writeJSONResponse(w, rm, http.StatusOK)
+}
+
+func (s *server) handleAdminRemovals(w http.ResponseWriter, r *http.Request) {
+	if _, ok := s.authAdmin(w, r); !ok {
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Requires GET", http.StatusMethodNotAllowed)
+		return
+	}
+	limit := 100
+	if v := r.FormValue("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "limit must be from 1 to 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	rms, err := s.db.Removals(r.Context(), limit)
+	if err != nil {
+		s.log.Errorf("listing removals: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if rms == nil {
+		rms = []*snippets.Removal{}
+	}
+	s.writeJSONResponse(w, rms, http.StatusOK)
+}
@@ -0,0 +1,123 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/playground/internal/snippets"
+)
+
+func TestLoadAdminKeys(t *testing.T) {
+	key := strings.Repeat("k", minAdminKeyLen)
+	for _, tc := range []struct {
+		desc, data string
+		wantErr    bool
+	}{
+		{"valid", "# admins\nalice " + key + "\n\nbob\t" + key + "2\n", false},
+		{"empty", "# no one\n", true},
+		{"short key", "alice short\n", true},
+		{"missing key", "alice\n", true},
+		{"duplicate", "alice " + key + "\nalice " + key + "\n", true},
+	} {
+		name := filepath.Join(t.TempDir(), "keys")
+		if err := os.WriteFile(name, []byte(tc.data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		keys, err := loadAdminKeys(name)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: loadAdminKeys = %v, %v; want error: %v", tc.desc, keys, err, tc.wantErr)
+			continue
+		}
+		if err == nil && (string(keys["alice"]) != key || string(keys["bob"]) != key+"2") {
+			t.Errorf("%s: loadAdminKeys = %q", tc.desc, keys)
+		}
+	}
+}
+
+func TestAdminRemove(t *testing.T) {
+	s, err := newServer(testingOptions(t))
+	if err != nil {
+		t.Fatalf("newServer(testingOptions(t)): %v", err)
+	}
+	key := strings.Repeat("k", minAdminKeyLen)
+	do := func(method, url, token, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, req)
+		return w
+	}
+
+	const removeDeleted = `{"ID":"deleted","Action":"delete","Reason":"leaked credential"}`
+	if w := do("POST", "/admin/remove", key, removeDeleted); w.Code != http.StatusNotFound {
+		t.Errorf("without admin keys, POST /admin/remove: status %d; want %d", w.Code, http.StatusNotFound)
+	}
+	s.adminKeys = map[string][]byte{"alice": []byte(key)}
+
+	for _, snip := range []*snippets.Snippet{{Body: []byte("secret")}, {Body: []byte("abuse")}} {
+		if err := s.db.PutSnippet(context.Background(), string(snip.Body), snip); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		desc, token, body string
+		want              int
+	}{
+		{"no key", "", removeDeleted, http.StatusUnauthorized},
+		{"wrong key", strings.Repeat("x", minAdminKeyLen), removeDeleted, http.StatusUnauthorized},
+		{"bad ID", key, `{"ID":"../x","Action":"delete","Reason":"r"}`, http.StatusBadRequest},
+		{"bad action", key, `{"ID":"secret","Action":"hide","Reason":"r"}`, http.StatusBadRequest},
+		{"no reason", key, `{"ID":"secret","Action":"delete"}`, http.StatusBadRequest},
+		{"delete", key, `{"ID":"secret","Action":"delete","Reason":"leaked credential"}`, http.StatusOK},
+		{"block", key, `{"ID":"abuse","Action":"block","Reason":"abuse"}`, http.StatusOK},
+		{"block unshared", key, `{"ID":"N_M_YelfGeR","Action":"block","Reason":"preemptive"}`, http.StatusOK},
+	} {
+		if w := do("POST", "/admin/remove", tc.token, tc.body); w.Code != tc.want {
+			t.Errorf("%s: POST /admin/remove: status %d; want %d: %s", tc.desc, w.Code, tc.want, w.Body)
+		}
+	}
+
+	var got snippets.Snippet
+	if err := s.db.GetSnippet(context.Background(), "secret", &got); err != nil || got.Body != nil || got.Removed.IsZero() {
+		t.Errorf("deleted snippet = %+v, %v; want a tombstone without a body", got, err)
+	}
+	if err := s.db.GetSnippet(context.Background(), "abuse", &got); err != nil || string(got.Body) != "abuse" || got.Removed.IsZero() {
+		t.Errorf("blocked snippet = %+v, %v; want a tombstone with its body", got, err)
+	}
+
+	for _, url := range []string{"/p/secret", "/p/abuse.go", "/p/abuse.json", "/p/N_M_YelfGeR"} {
+		if w := do("GET", url, "", ""); w.Code != http.StatusGone {
+			t.Errorf("GET %s: status %d; want %d", url, w.Code, http.StatusGone)
+		}
+	}
+	// "Snippy McSnipface" has the blocked ID N_M_YelfGeR.
+	if w := do("POST", "/share", "", "Snippy McSnipface"); w.Code != http.StatusGone {
+		t.Errorf("sharing a blocked snippet: status %d; want %d", w.Code, http.StatusGone)
+	}
+
+	if w := do("GET", "/admin/removals?limit=2", "", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("GET /admin/removals without a key: status %d; want %d", w.Code, http.StatusUnauthorized)
+	}
+	w := do("GET", "/admin/removals?limit=2", key, "")
+	var rms []snippets.Removal
+	if err := json.Unmarshal(w.Body.Bytes(), &rms); err != nil {
+		t.Fatalf("GET /admin/removals: %v: %s", err, w.Body)
+	}
+	if len(rms) != 2 || rms[0].ID != "N_M_YelfGeR" || rms[1].ID != "abuse" || rms[1].Admin != "alice" || rms[1].Reason != "abuse" {
+		t.Errorf("GET /admin/removals = %+v; want the last two removals, newest first", rms)
+	}
+}
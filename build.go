@@ -0,0 +1,420 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/playground/sandbox/sandboxtypes"
+)
+
+// The playground can run as a build service, with -serve-builds, that
+// builds programs for frontends configured with -build-url. That
+// keeps module downloads and the go command off the frontends, and
+// lets build and run capacity scale separately.
+//
+// Requests to the build service are signed with a key shared with
+// the frontends, given by -build-auth-key-file.
+
+// buildServiceSlack is how much longer than policy.MaxBuildTime a
+// frontend waits for a build service, to allow for transferring the
+// source and binary.
+const buildServiceSlack = 10 * time.Second
+
+// buildAuthKey is the key shared between the frontends and the build
+// service that build requests are signed with, or nil if they aren't.
+var buildAuthKey []byte
+
+// buildVerifier authenticates requests to the build service. It's set
+// by serveBuildService.
+var buildVerifier *sandboxtypes.RunVerifier
+
+// egressProxyURL, if set, is the URL of the egressProxy the go command
+// must use for network access.
+var egressProxyURL string
+
+// pinModuleEnv is whether goproxyEnv overrides the environment's
+// module settings. It's set by serveBuildService, as a build service
+// runs the go command for other people's programs.
+var pinModuleEnv bool
+
+// buildProgram builds the program in src, on the build service if
+// there is one and otherwise in tmpDir. Either way, the binary, if
+// any, is written to tmpDir.
+func buildProgram(ctx context.Context, tmpDir string, src []byte, vet bool) (*buildResult, error) {
+	if *buildURL == "" {
+		return sandboxBuild(ctx, tmpDir, src, vet)
+	}
+	return remoteBuild(ctx, *buildURL, tmpDir, src, vet)
+}
+
+// buildServiceClient returns the client for requests to the build
+// service.
+func buildServiceClient() *http.Client {
+	return &http.Client{Timeout: policy.MaxBuildTime + buildServiceSlack}
+}
+
+// postBuildService sends breq to the endpoint at path of the build
+// service at baseURL, accepting a response of type accept, and returns
+// the response if its status is OK. The caller must close the
+// response body.
+func postBuildService(ctx context.Context, baseURL, path, accept string, breq *sandboxtypes.BuildRequest) (*http.Response, error) {
+	body, err := json.Marshal(breq)
+	if err != nil {
+		return nil, err
+	}
+	u := strings.TrimSuffix(baseURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, "POST", u, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("NewRequestWithContext %q: %w", u, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	if buildAuthKey != nil {
+		if err := sandboxtypes.SignBuild(req, buildAuthKey, body, time.Now()); err != nil {
+			return nil, fmt.Errorf("signing build request: %w", err)
+		}
+	}
+	res, err := buildServiceClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("POST %q: %w", u, err)
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("unexpected response from build service: %v", res.Status)
+	}
+	return res, nil
+}
+
+// remoteBuild builds the program in src on the build service at
+// baseURL, and writes the binary to tmpDir.
+func remoteBuild(ctx context.Context, baseURL, tmpDir string, src []byte, vet bool) (*buildResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, policy.MaxBuildTime+buildServiceSlack)
+	defer cancel()
+	res, err := postBuildService(ctx, baseURL, "/build", sandboxtypes.FramesContentType, &sandboxtypes.BuildRequest{Body: string(src), WithVet: vet})
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	r, err := sandboxtypes.ReadBuildResultFrames(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response from build service: %w", err)
+	}
+	br := &buildResult{
+		dir:          r.Dir,
+		testParam:    r.TestParam,
+		dataFiles:    r.Files,
+		errorMessage: r.Errors,
+		vetOut:       r.VetErrors,
+	}
+	if br.errorMessage != "" {
+		return br, nil
+	}
+	br.exePath = filepath.Join(tmpDir, "a.out")
+	if err := os.WriteFile(br.exePath, r.Binary, 0755); err != nil {
+		return nil, err
+	}
+	return br, nil
+}
+
+// remoteVet runs go vet on the Go file src on the build service at
+// baseURL, and returns its output.
+func remoteVet(ctx context.Context, baseURL string, src []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, policy.MaxBuildTime+buildServiceSlack)
+	defer cancel()
+	res, err := postBuildService(ctx, baseURL, "/vet", "application/json", &sandboxtypes.BuildRequest{Body: string(src)})
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	var vr sandboxtypes.VetResult
+	if err := json.NewDecoder(res.Body).Decode(&vr); err != nil {
+		return "", fmt.Errorf("reading response from build service: %w", err)
+	}
+	return vr.Errors, nil
+}
+
+// buildServiceHandler returns the handler of a build service.
+func buildServiceHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/build", handleBuild)
+	mux.HandleFunc("/vet", handleVet)
+	mux.HandleFunc("/_ah/health", handleBuildHealth)
+	mux.HandleFunc("/healthz", handleBuildHealth)
+	return mux
+}
+
+// readBuildRequest reads the sandboxtypes.BuildRequest in r, a
+// request to the build service. If r isn't a valid, authenticated
+// request, it replies with an error and returns nil.
+func readBuildRequest(w http.ResponseWriter, r *http.Request) *sandboxtypes.BuildRequest {
+	if r.Method != "POST" {
+		http.Error(w, "expected a POST", http.StatusMethodNotAllowed)
+		return nil
+	}
+	// Check the headers before reading what may be a large body.
+	if buildVerifier != nil {
+		if err := buildVerifier.CheckHeaders(r, time.Now()); err != nil {
+			log.Printf("rejected unauthenticated %s request from %s: %v", r.URL.Path, r.RemoteAddr, err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return nil
+		}
+	}
+	h := sha256.New()
+	body, err := io.ReadAll(io.TeeReader(http.MaxBytesReader(w, r.Body, policy.MaxRequestSize), h))
+	if err != nil {
+		if isTooLarge(err) {
+			http.Error(w, "Request is too large", http.StatusRequestEntityTooLarge)
+			return nil
+		}
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return nil
+	}
+	if buildVerifier != nil {
+		if err := buildVerifier.VerifyDigest(r, h.Sum(nil), time.Now()); err != nil {
+			log.Printf("rejected unauthenticated %s request from %s: %v", r.URL.Path, r.RemoteAddr, err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return nil
+		}
+	}
+	req := new(sandboxtypes.BuildRequest)
+	if err := json.Unmarshal(body, req); err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return nil
+	}
+	return req
+}
+
+// handleBuild builds the program in a sandboxtypes.BuildRequest and
+// replies with a framed sandboxtypes.BuildResult.
+func handleBuild(w http.ResponseWriter, r *http.Request) {
+	req := readBuildRequest(w, r)
+	if req == nil {
+		return
+	}
+
+	tmpDir, err := os.MkdirTemp("", "sandbox")
+	if err != nil {
+		log.Errorf("error creating temp directory: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+	br, err := sandboxBuild(r.Context(), tmpDir, []byte(req.Body), req.WithVet)
+	if err != nil {
+		log.Errorf("error building program: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	res := &sandboxtypes.BuildResult{
+		Errors:    br.errorMessage,
+		VetErrors: br.vetOut,
+		TestParam: br.testParam,
+		Dir:       br.dir,
+	}
+	if res.Errors == "" {
+		if res.Binary, err = os.ReadFile(br.exePath); err != nil {
+			log.Errorf("error reading binary: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		res.Files = br.dataFiles
+	}
+	data, err := res.MarshalFrames()
+	if err != nil {
+		log.Errorf("error encoding build result: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", sandboxtypes.FramesContentType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	w.Write(data)
+}
+
+// handleVet runs go vet on the Go file in a sandboxtypes.BuildRequest
+// and replies with a sandboxtypes.VetResult, for the legacy /vet
+// endpoint.
+func handleVet(w http.ResponseWriter, r *http.Request) {
+	req := readBuildRequest(w, r)
+	if req == nil {
+		return
+	}
+	resp, err := vetCheck(r.Context(), &request{Body: req.Body})
+	if err != nil {
+		log.Errorf("error vetting program: %v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&sandboxtypes.VetResult{Errors: resp.Errors})
+}
+
+// handleBuildHealth reports whether the build service can build
+// healthProg.
+func handleBuildHealth(w http.ResponseWriter, r *http.Request) {
+	tmpDir, err := os.MkdirTemp("", "sandbox")
+	if err == nil {
+		defer os.RemoveAll(tmpDir)
+		var br *buildResult
+		if br, err = sandboxBuild(r.Context(), tmpDir, []byte(healthProg), false); err == nil && br.errorMessage != "" {
+			err = errors.New(br.errorMessage)
+		}
+	}
+	if err != nil {
+		log.Errorf("health check failed: %v", err)
+		http.Error(w, "Health check failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprint(w, "ok")
+}
+
+// goproxyEnv returns the environment variables that configure the
+// go command's access to modules: GOPROXY, from playgroundGoproxy,
+// and, if pinModuleEnv is set, ones that override any in the
+// environment that would let it fetch modules other than through
+// GOPROXY, or skip checking them against the checksum database.
+func goproxyEnv() []string {
+	env := []string{"GOPROXY=" + playgroundGoproxy()}
+	if pinModuleEnv {
+		env = append(env,
+			"GOFLAGS=-mod=mod",
+			"GONOPROXY=",
+			"GONOSUMDB=",
+			"GOPRIVATE=",
+			"GOINSECURE=",
+			"GOSUMDB=sum.golang.org",
+		)
+	}
+	if egressProxyURL != "" {
+		// The egress proxy only allows the module proxies, so
+		// don't let the go command try version control systems.
+		env = append(env, "GOVCS=*:off", "HTTPS_PROXY="+egressProxyURL, "HTTP_PROXY="+egressProxyURL, "NO_PROXY=")
+	}
+	return env
+}
+
+// An egressProxy is an HTTP proxy that only forwards requests to the
+// module proxies, so that the go command can't reach anything else.
+type egressProxy struct {
+	allowed map[string]bool // host:port
+}
+
+// newEgressProxy returns a proxy that allows access to the proxies in
+// goproxy, a GOPROXY value.
+func newEgressProxy(goproxy string) (*egressProxy, error) {
+	p := &egressProxy{allowed: make(map[string]bool)}
+	for _, s := range strings.FieldsFunc(goproxy, func(r rune) bool { return r == ',' || r == '|' }) {
+		if s == "direct" || s == "off" {
+			continue
+		}
+		u, err := url.Parse(s)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid GOPROXY entry %q", s)
+		}
+		host := u.Host
+		if u.Port() == "" {
+			port := "443"
+			if u.Scheme == "http" {
+				port = "80"
+			}
+			host = net.JoinHostPort(u.Hostname(), port)
+		}
+		p.allowed[host] = true
+	}
+	if len(p.allowed) == 0 {
+		return nil, fmt.Errorf("GOPROXY %q names no proxies", goproxy)
+	}
+	return p, nil
+}
+
+// startEgressProxy starts an egressProxy for playgroundGoproxy on a
+// loopback port and returns its URL.
+func startEgressProxy() (string, error) {
+	p, err := newEgressProxy(playgroundGoproxy())
+	if err != nil {
+		return "", err
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	go http.Serve(ln, p)
+	return "http://" + ln.Addr().String(), nil
+}
+
+func (p *egressProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.serveConnect(w, r)
+		return
+	}
+	host := r.URL.Host
+	if r.URL.Port() == "" {
+		host = net.JoinHostPort(r.URL.Hostname(), "80")
+	}
+	if r.URL.Scheme != "http" || !p.allowed[host] {
+		http.Error(w, "only the module proxy may be reached", http.StatusForbidden)
+		return
+	}
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.Header.Del("Proxy-Connection")
+	out.Header.Del("Proxy-Authorization")
+	res, err := http.DefaultTransport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer res.Body.Close()
+	for k, vv := range res.Header {
+		w.Header()[k] = vv
+	}
+	w.WriteHeader(res.StatusCode)
+	io.Copy(w, res.Body)
+}
+
+// serveConnect tunnels a CONNECT request, as used for HTTPS.
+func (p *egressProxy) serveConnect(w http.ResponseWriter, r *http.Request) {
+	if !p.allowed[r.Host] {
+		http.Error(w, "only the module proxy may be reached", http.StatusForbidden)
+		return
+	}
+	upstream, err := net.DialTimeout("tcp", r.Host, 10*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n"); err != nil {
+		return
+	}
+	done := make(chan struct{})
+	go func() {
+		// Send anything the client already sent along with the request.
+		io.Copy(upstream, rw.Reader)
+		upstream.(*net.TCPConn).CloseWrite()
+		close(done)
+	}()
+	io.Copy(conn, upstream)
+	<-done
+}
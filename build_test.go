@@ -0,0 +1,206 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/playground/sandbox/sandboxtypes"
+)
+
+// withBuildAuthKey sets buildAuthKey and buildVerifier to use a test
+// key until t is done.
+func withBuildAuthKey(t *testing.T) {
+	oldKey, oldVerifier := buildAuthKey, buildVerifier
+	t.Cleanup(func() { buildAuthKey, buildVerifier = oldKey, oldVerifier })
+	buildAuthKey = []byte(strings.Repeat("b", 32))
+	buildVerifier = sandboxtypes.NewBuildVerifier(buildAuthKey)
+}
+
+func TestRemoteBuild(t *testing.T) {
+	withBuildAuthKey(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/build" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		req := readBuildRequest(w, r)
+		if req == nil {
+			return
+		}
+		res := &sandboxtypes.BuildResult{Dir: "/tmp/sandbox1", VetErrors: "vet: " + req.Body}
+		if req.Body == "broken" {
+			res.Errors = "syntax error"
+		} else {
+			res.Binary = []byte("binary")
+			res.Files = map[string][]byte{"testdata/in.txt": []byte("data")}
+		}
+		b, _ := res.MarshalFrames()
+		w.Header().Set("Content-Type", sandboxtypes.FramesContentType)
+		w.Write(b)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	br, err := remoteBuild(t.Context(), server.URL, tmpDir, []byte("ok"), true)
+	if err != nil {
+		t.Fatalf("remoteBuild: %v", err)
+	}
+	if br.dir != "/tmp/sandbox1" || br.vetOut != "vet: ok" || string(br.dataFiles["testdata/in.txt"]) != "data" {
+		t.Errorf("remoteBuild = %+v; want dir, vet output and data file from build service", br)
+	}
+	if b, err := os.ReadFile(br.exePath); err != nil || string(b) != "binary" {
+		t.Errorf("reading binary = %q, %v; want %q", b, err, "binary")
+	}
+
+	br, err = remoteBuild(t.Context(), server.URL, tmpDir, []byte("broken"), false)
+	if err != nil {
+		t.Fatalf("remoteBuild: %v", err)
+	}
+	if br.errorMessage != "syntax error" || br.exePath != "" {
+		t.Errorf("remoteBuild of broken program = %+v; want errors and no binary", br)
+	}
+}
+
+func TestRemoteVet(t *testing.T) {
+	withBuildAuthKey(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/vet" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		req := readBuildRequest(w, r)
+		if req == nil {
+			return
+		}
+		json.NewEncoder(w).Encode(&sandboxtypes.VetResult{Errors: "vet: " + req.Body})
+	}))
+	defer server.Close()
+
+	out, err := remoteVet(t.Context(), server.URL, []byte("prog"))
+	if err != nil || out != "vet: prog" {
+		t.Errorf("remoteVet = %q, %v; want %q, nil", out, err, "vet: prog")
+	}
+}
+
+func TestBuildServiceAuth(t *testing.T) {
+	withBuildAuthKey(t)
+	handler := buildServiceHandler()
+	do := func(path, body string, sign bool) int {
+		t.Helper()
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		if sign {
+			if err := sandboxtypes.SignBuild(req, buildAuthKey, []byte(body), time.Now()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	for _, path := range []string{"/build", "/vet"} {
+		if code := do(path, `{"body":"package main"}`, false); code != http.StatusUnauthorized {
+			t.Errorf("unsigned POST %s: status %d; want %d", path, code, http.StatusUnauthorized)
+		}
+		// A signed request gets as far as decoding the body.
+		if code := do(path, "not JSON", true); code != http.StatusBadRequest {
+			t.Errorf("signed POST %s of a bad body: status %d; want %d", path, code, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestGoproxyEnv(t *testing.T) {
+	oldURL, oldPin := egressProxyURL, pinModuleEnv
+	defer func() { egressProxyURL, pinModuleEnv = oldURL, oldPin }()
+	t.Setenv("PLAY_GOPROXY", "https://proxy.example.com")
+
+	// A frontend building locally leaves the environment's module
+	// settings alone.
+	pinModuleEnv = false
+	if got, want := goproxyEnv(), []string{"GOPROXY=https://proxy.example.com"}; !slices.Equal(got, want) {
+		t.Errorf("goproxyEnv() for a local build = %q; want %q", got, want)
+	}
+
+	pinModuleEnv = true
+	egressProxyURL = "http://127.0.0.1:1234"
+
+	// The go command uses the last value of each variable, so these
+	// override any from the environment.
+	env := make(map[string]string)
+	for _, kv := range goproxyEnv() {
+		k, v, _ := strings.Cut(kv, "=")
+		env[k] = v
+	}
+	for k, want := range map[string]string{
+		"GOPROXY":     "https://proxy.example.com",
+		"GOFLAGS":     "-mod=mod",
+		"GONOSUMDB":   "",
+		"GOPRIVATE":   "",
+		"GOSUMDB":     "sum.golang.org",
+		"GOVCS":       "*:off",
+		"HTTPS_PROXY": egressProxyURL,
+	} {
+		if got, ok := env[k]; !ok || got != want {
+			t.Errorf("goproxyEnv() sets %s=%q (set: %t); want %q", k, got, ok, want)
+		}
+	}
+}
+
+func TestEgressProxy(t *testing.T) {
+	allowed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "module")
+	}))
+	defer allowed.Close()
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "elsewhere")
+	}))
+	defer denied.Close()
+
+	allowedTLS := httptest.NewTLSServer(allowed.Config.Handler)
+	defer allowedTLS.Close()
+
+	p, err := newEgressProxy(allowed.URL + "," + allowedTLS.URL + ",direct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httptest.NewServer(p)
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	transport := allowedTLS.Client().Transport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	client := &http.Client{Transport: transport}
+
+	for _, tc := range []struct {
+		url  string
+		want int
+	}{
+		{allowed.URL + "/golang.org/x/text/@v/list", http.StatusOK},
+		{allowedTLS.URL + "/golang.org/x/text/@v/list", http.StatusOK},
+		{denied.URL, http.StatusForbidden},
+	} {
+		res, err := client.Get(tc.url)
+		if err != nil {
+			t.Errorf("GET %s: %v", tc.url, err)
+			continue
+		}
+		res.Body.Close()
+		if res.StatusCode != tc.want {
+			t.Errorf("GET %s via proxy = %v; want %d", tc.url, res.Status, tc.want)
+		}
+	}
+
+	if _, err := newEgressProxy("direct"); err == nil {
+		t.Errorf("newEgressProxy(%q) = nil error; want an error", "direct")
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// The snippettool command exports the playground's shared snippets
+// to a portable archive, and imports such an archive into a snippet
+// store. It is used for backups, and to move snippets between Cloud
+// Datastore and a self-hosted playground's -snippet-dir.
+//
+// Usage:
+//
+//	snippettool [-project=id | -dir=path] export [archive.tar.gz]
+//	snippettool [-project=id | -dir=path] import archive.tar.gz
+//
+// Mutable snippets are exported and imported along with the others.
+// Export writes to standard output if no archive is named, and skips,
+// with a message, snippets the store can't read, such as corrupt
+// files in a -dir. Import
+// checks that each snippet's body has the ID it's archived under, and
+// skips, with a message, those that don't, and mutable snippets whose
+// current revision wasn't imported.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"cloud.google.com/go/datastore"
+	"golang.org/x/playground/internal/snippets"
+)
+
+var (
+	project = flag.String("project", "", "GCP project whose Cloud Datastore holds the snippets")
+	dir     = flag.String("dir", "", "directory that holds the snippets, as with the playground's -snippet-dir")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: snippettool [-project=id | -dir=path] export [archive.tar.gz]\n")
+	fmt.Fprintf(os.Stderr, "       snippettool [-project=id | -dir=path] import archive.tar.gz\n")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	log.SetFlags(0)
+	log.SetPrefix("snippettool: ")
+	if flag.NArg() < 1 || flag.NArg() > 2 || (*project == "") == (*dir == "") {
+		usage()
+	}
+
+	ctx := context.Background()
+	st, err := openStore(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch flag.Arg(0) {
+	case "export":
+		var w io.Writer = os.Stdout
+		var f *os.File
+		if name := flag.Arg(1); name != "" {
+			if f, err = os.Create(name); err != nil {
+				log.Fatal(err)
+			}
+			w = f
+		}
+		skipped := 0
+		n, err := snippets.Export(ctx, st, w, func(name string, err error) {
+			log.Printf("skipping snippet %s: %v", name, err)
+			skipped++
+		})
+		if err == nil && f != nil {
+			err = f.Close()
+		}
+		if err != nil {
+			log.Fatalf("exported %d snippets before failing: %v", n, err)
+		}
+		log.Printf("exported %d snippets, skipped %d", n, skipped)
+		if skipped > 0 {
+			os.Exit(1)
+		}
+
+	case "import":
+		if flag.NArg() != 2 {
+			usage()
+		}
+		f, err := os.Open(flag.Arg(1))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		skipped := 0
+		n, err := snippets.Import(ctx, st, f, func(id string, err error) {
+			log.Printf("skipping snippet %s: %v", id, err)
+			skipped++
+		})
+		if err != nil {
+			log.Fatalf("imported %d snippets before failing: %v", n, err)
+		}
+		log.Printf("imported %d snippets, skipped %d", n, skipped)
+		if skipped > 0 {
+			os.Exit(1)
+		}
+
+	default:
+		usage()
+	}
+}
+
+// openStore opens the store selected by -project or -dir.
+func openStore(ctx context.Context) (snippets.Store, error) {
+	if *dir != "" {
+		return snippets.NewFileStore(*dir)
+	}
+	c, err := datastore.NewClient(ctx, *project)
+	if err != nil {
+		return nil, fmt.Errorf("could not create cloud datastore client: %v", err)
+	}
+	return snippets.Datastore{Client: c}, nil
+}
@@ -0,0 +1,146 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Crash describes a runtime crash (an unrecovered panic or a fatal
+// runtime error such as a deadlock) found in a program's standard
+// error, with its goroutine stacks parsed.
+type Crash struct {
+	Kind       string // "panic", "deadlock", or "fatal error"
+	Value      string // panic value or fatal error message
+	Goroutines []Goroutine
+}
+
+// Goroutine is a single goroutine's stack from a crash traceback.
+type Goroutine struct {
+	ID        int
+	State     string // e.g. "running", "chan receive"
+	Frames    []Frame
+	CreatedBy *Frame `json:",omitempty"` // the go statement that started it, if known
+}
+
+// Frame is a single stack frame from a crash traceback.
+type Frame struct {
+	Func string
+	// File is the name of the user's txtar file for user frames,
+	// or the original path for runtime and standard library frames.
+	File string
+	Line int
+	User bool // whether File is one of the user's files
+}
+
+var goroutineHeader = regexp.MustCompile(`^goroutine (\d+)(?: [^\[]*)? \[(.*)\]:$`)
+
+// parseCrash looks for a runtime crash in the stderr events and
+// returns it, or nil if the program did not crash.
+//
+// Frame file names under dir, the directory the program was built in,
+// are made relative to dir and, if present in rename, replaced by the
+// name the user gave that file.
+func parseCrash(events []Event, dir string, rename map[string]string) *Crash {
+	var stderr strings.Builder
+	for _, e := range events {
+		if e.Kind == "stderr" {
+			stderr.WriteString(e.Message)
+		}
+	}
+	lines := strings.Split(stderr.String(), "\n")
+
+	var c *Crash
+	i := 0
+	for ; i < len(lines); i++ {
+		if v, ok := strings.CutPrefix(lines[i], "panic: "); ok {
+			c = &Crash{Kind: "panic", Value: v}
+			break
+		}
+		if v, ok := strings.CutPrefix(lines[i], "fatal error: "); ok {
+			c = &Crash{Kind: "fatal error", Value: v}
+			if strings.HasPrefix(v, "all goroutines are asleep - deadlock!") {
+				c.Kind = "deadlock"
+			}
+			break
+		}
+	}
+	if c == nil {
+		return nil
+	}
+	// Continuation lines of the value, such as nested panics or the
+	// [signal ...] line, run until the first blank line.
+	for i++; i < len(lines) && lines[i] != ""; i++ {
+		if goroutineHeader.MatchString(lines[i]) {
+			break
+		}
+		c.Value += "\n" + lines[i]
+	}
+
+	var g *Goroutine
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if m := goroutineHeader.FindStringSubmatch(line); m != nil {
+			id, _ := strconv.Atoi(m[1])
+			c.Goroutines = append(c.Goroutines, Goroutine{ID: id, State: m[2]})
+			g = &c.Goroutines[len(c.Goroutines)-1]
+			continue
+		}
+		switch {
+		case line == "":
+			g = nil
+			continue
+		case g == nil, strings.HasPrefix(line, "\t"), strings.HasPrefix(line, "..."):
+			continue
+		}
+		// A function line, followed by its file:line.
+		fn, created := strings.CutPrefix(line, "created by ")
+		if created {
+			fn, _, _ = strings.Cut(fn, " in goroutine ")
+		} else if strings.HasSuffix(fn, ")") {
+			if j := strings.LastIndex(fn, "("); j > 0 {
+				fn = fn[:j]
+			}
+		}
+		f := Frame{Func: fn}
+		if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "\t") {
+			i++
+			f.File, f.Line, f.User = parseFrameLocation(lines[i], dir, rename)
+		}
+		if created {
+			g.CreatedBy = &f
+		} else {
+			g.Frames = append(g.Frames, f)
+		}
+	}
+	return c
+}
+
+// parseFrameLocation parses a traceback location line of the form
+// "\t/path/to/file.go:123 +0x1d".
+func parseFrameLocation(s, dir string, rename map[string]string) (file string, line int, user bool) {
+	s = strings.TrimPrefix(s, "\t")
+	if j := strings.LastIndex(s, " +0x"); j >= 0 {
+		s = s[:j]
+	}
+	file = s
+	if j := strings.LastIndex(s, ":"); j >= 0 {
+		if n, err := strconv.Atoi(s[j+1:]); err == nil {
+			file, line = s[:j], n
+		}
+	}
+	if dir != "" {
+		if rel, ok := strings.CutPrefix(file, filepath.ToSlash(dir)+"/"); ok {
+			file, user = rel, true
+			if name, ok := rename[file]; ok {
+				file = name
+			}
+		}
+	}
+	return file, line, user
+}
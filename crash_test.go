@@ -0,0 +1,134 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseCrash(t *testing.T) {
+	const dir = "/tmp/sandbox123"
+	for _, tt := range []struct {
+		name   string
+		events []Event
+		rename map[string]string
+		want   *Crash
+	}{
+		{
+			name: "no crash",
+			events: []Event{
+				{"panic: not really\n", "stdout", 0},
+				{"some warning\n", "stderr", 0},
+			},
+			want: nil,
+		},
+		{
+			name: "nil dereference",
+			events: []Event{
+				{"hello\n", "stdout", 0},
+				{`panic: runtime error: invalid memory address or nil pointer dereference
+[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x47a2d5]
+
+goroutine 1 [running]:
+main.deref(...)
+	/tmp/sandbox123/util.go:4
+main.main()
+	/tmp/sandbox123/prog.go:9 +0x15
+`, "stderr", 0},
+			},
+			want: &Crash{
+				Kind:  "panic",
+				Value: "runtime error: invalid memory address or nil pointer dereference\n[signal SIGSEGV: segmentation violation code=0x1 addr=0x0 pc=0x47a2d5]",
+				Goroutines: []Goroutine{{
+					ID:    1,
+					State: "running",
+					Frames: []Frame{
+						{Func: "main.deref", File: "util.go", Line: 4, User: true},
+						{Func: "main.main", File: "prog.go", Line: 9, User: true},
+					},
+				}},
+			},
+		},
+		{
+			name: "deadlock",
+			events: []Event{
+				{"fatal error: all goroutines are asleep - deadlock!\n\n", "stderr", 0},
+				{`goroutine 1 [chan receive]:
+main.main()
+	/tmp/sandbox123/prog.go:7 +0x34
+
+goroutine 18 [chan send]:
+main.worker(0xc000012345)
+	/tmp/sandbox123/prog.go:12 +0x1d
+created by main.main in goroutine 1
+	/tmp/sandbox123/prog.go:6 +0x25
+
+goroutine 19 [select (no cases)]:
+runtime.block()
+	/usr/local/go-faketime/src/runtime/select.go:104 +0x26
+`, "stderr", 0},
+			},
+			want: &Crash{
+				Kind:  "deadlock",
+				Value: "all goroutines are asleep - deadlock!",
+				Goroutines: []Goroutine{
+					{
+						ID:     1,
+						State:  "chan receive",
+						Frames: []Frame{{Func: "main.main", File: "prog.go", Line: 7, User: true}},
+					},
+					{
+						ID:        18,
+						State:     "chan send",
+						Frames:    []Frame{{Func: "main.worker", File: "prog.go", Line: 12, User: true}},
+						CreatedBy: &Frame{Func: "main.main", File: "prog.go", Line: 6, User: true},
+					},
+					{
+						ID:     19,
+						State:  "select (no cases)",
+						Frames: []Frame{{Func: "runtime.block", File: "/usr/local/go-faketime/src/runtime/select.go", Line: 104}},
+					},
+				},
+			},
+		},
+		{
+			name: "test panic",
+			events: []Event{
+				{`--- FAIL: TestBoom (0.00s)
+panic: boom [recovered]
+	panic: boom
+
+goroutine 7 [running]:
+testing.tRunner.func1.2({0x4e1f00, 0x5a12d0})
+	/usr/local/go-faketime/src/testing/testing.go:1632 +0x230
+main.TestBoom(0xc0000a2000?)
+	/tmp/sandbox123/prog_test.go:6 +0x25
+`, "stderr", 0},
+			},
+			rename: map[string]string{progTestName: progName},
+			want: &Crash{
+				Kind:  "panic",
+				Value: "boom [recovered]\n\tpanic: boom",
+				Goroutines: []Goroutine{{
+					ID:    7,
+					State: "running",
+					Frames: []Frame{
+						{Func: "testing.tRunner.func1.2", File: "/usr/local/go-faketime/src/testing/testing.go", Line: 1632},
+						{Func: "main.TestBoom", File: "prog.go", Line: 6, User: true},
+					},
+				}},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseCrash(tt.events, dir, tt.rename)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("parseCrash mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -12,14 +12,24 @@ import (
 	"strings"
 
 	"cloud.google.com/go/datastore"
+	"golang.org/x/playground/internal/snippets"
 )
 
 const hostname = "play.golang.org"
 
 var editTemplate = template.Must(template.ParseFiles("edit.html"))
 
+// removedTemplate is the page served for a snippet that was removed.
+var removedTemplate = template.Must(template.New("removed").Parse(`<!DOCTYPE html>
+<title>Snippet removed - The Go Playground</title>
+<h1>Snippet removed</h1>
+<p>The snippet {{.}} was removed by the Go Playground's administrators
+and is no longer available.</p>
+<p><a href="/">Go to the Go Playground</a></p>
+`))
+
 type editData struct {
-	Snippet   *snippet
+	Snippet   *snippets.Snippet
 	Analytics bool
 	GoVersion string
 	Gotip     bool
@@ -40,18 +50,43 @@ func (s *server) handleEdit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Serve 404 for /foo.
-	if r.URL.Path != "/" && !strings.HasPrefix(r.URL.Path, "/p/") {
+	mutable := strings.HasPrefix(r.URL.Path, "/m/")
+	if r.URL.Path != "/" && !strings.HasPrefix(r.URL.Path, "/p/") && !mutable {
 		http.NotFound(w, r)
 		return
 	}
 
-	snip := &snippet{Body: []byte(s.examples.hello())}
-	if strings.HasPrefix(r.URL.Path, "/p/") {
+	snip := &snippets.Snippet{Body: []byte(s.examples.hello())}
+	if strings.HasPrefix(r.URL.Path, "/p/") || mutable {
 		id := r.URL.Path[3:]
-		serveText := false
+		serveText, serveMeta := false, false
 		if strings.HasSuffix(id, ".go") {
 			id = id[:len(id)-3]
 			serveText = true
+		} else if strings.HasSuffix(id, ".json") {
+			id = id[:len(id)-5]
+			serveMeta = true
+		}
+
+		if mutable {
+			var m snippets.Mutable
+			if err := s.db.GetMutable(r.Context(), id, &m); err != nil {
+				if err != datastore.ErrNoSuchEntity {
+					s.log.Errorf("loading mutable Snippet: %v", err)
+				}
+				http.Error(w, "Snippet not found", http.StatusNotFound)
+				return
+			}
+			if !m.Removed.IsZero() {
+				s.serveRemoved(w, id, serveText || serveMeta)
+				return
+			}
+			if serveMeta {
+				s.writeJSONResponse(w, newMutableMeta(id, &m), http.StatusOK)
+				return
+			}
+			// Serve the current revision.
+			id = m.Head
 		}
 
 		if err := s.db.GetSnippet(r.Context(), id, snip); err != nil {
@@ -61,6 +96,10 @@ func (s *server) handleEdit(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Snippet not found", http.StatusNotFound)
 			return
 		}
+		if !snip.Removed.IsZero() {
+			s.serveRemoved(w, id, serveText || serveMeta)
+			return
+		}
 		if serveText {
 			if r.FormValue("download") == "true" {
 				w.Header().Set(
@@ -71,6 +110,10 @@ func (s *server) handleEdit(w http.ResponseWriter, r *http.Request) {
 			w.Write(snip.Body)
 			return
 		}
+		if serveMeta {
+			s.writeJSONResponse(w, newSnippetMeta(id, snip), http.StatusOK)
+			return
+		}
 	}
 
 	if r.Host == hostname {
@@ -91,3 +134,17 @@ func (s *server) handleEdit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// serveRemoved replies that the snippet id was removed, as plain text
+// if plain is set and as a page otherwise.
+func (s *server) serveRemoved(w http.ResponseWriter, id string, plain bool) {
+	if plain {
+		http.Error(w, "Snippet removed", http.StatusGone)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusGone)
+	if err := removedTemplate.Execute(w, id); err != nil {
+		s.log.Errorf("removedTemplate.Execute(w, %q): %v", id, err)
+	}
+}
@@ -26,6 +26,15 @@ func (s *server) handleFmt(w http.ResponseWriter, r *http.Request) {
 		// This is likely a pre-flight CORS request.
 		return
 	}
+	r.Body = http.MaxBytesReader(w, r.Body, policy.MaxRequestSize)
+	if err := r.ParseForm(); err != nil {
+		if isTooLarge(err) {
+			http.Error(w, "Request is too large", http.StatusRequestEntityTooLarge)
+		} else {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		}
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
 
 	fs, err := splitFiles([]byte(r.FormValue("body")))
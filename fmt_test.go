@@ -155,3 +155,19 @@ func TestHandleFmt(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleFmtTooLarge(t *testing.T) {
+	s, err := newServer(testingOptions(t))
+	if err != nil {
+		t.Fatalf("newServer(testingOptions(t)): %v", err)
+	}
+	form := url.Values{}
+	form.Set("body", strings.Repeat("x", int(policy.MaxRequestSize)))
+	req := httptest.NewRequest("POST", "/fmt", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	s.handleFmt(rec, req)
+	if got, want := rec.Code, http.StatusRequestEntityTooLarge; got != want {
+		t.Errorf("code = %d; want %d", got, want)
+	}
+}
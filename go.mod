@@ -12,6 +12,7 @@ require (
 	go.opencensus.io v0.24.0
 	golang.org/x/build v0.0.0-20260708222831-c49463d7ff26
 	golang.org/x/mod v0.38.0
+	golang.org/x/sys v0.47.0
 	golang.org/x/tools v0.48.0
 	golang.org/x/tools/godoc v0.1.0-deprecated
 	google.golang.org/api v0.154.0
@@ -55,7 +56,6 @@ require (
 	golang.org/x/net v0.57.0 // indirect
 	golang.org/x/oauth2 v0.36.0 // indirect
 	golang.org/x/sync v0.22.0 // indirect
-	golang.org/x/sys v0.47.0 // indirect
 	golang.org/x/text v0.40.0 // indirect
 	golang.org/x/time v0.15.0 // indirect
 	google.golang.org/genproto v0.0.0-20231120223509-83a465c0220f // indirect
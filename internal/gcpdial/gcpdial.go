@@ -81,7 +81,10 @@ func (p *prober) probe() {
 	}
 	ctx, cancel := context.WithTimeout(p.ctx, 30*time.Second)
 	defer cancel()
-	req, err := http.NewRequest("GET", "http://"+p.ip+"/healthz", nil)
+	// Probe readiness rather than liveness, so that sandboxes that
+	// are draining or can't run binaries don't get new requests.
+	// Busy sandboxes stay ready, and queue requests fairly.
+	req, err := http.NewRequest("GET", "http://"+p.ip+"/readyz", nil)
 	if err != nil {
 		log.Printf("gcpdial: prober %s: NewRequest: %v", p.instURL, err)
 		return
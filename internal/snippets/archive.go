@@ -0,0 +1,247 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package snippets
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// An archive holds snippets in a portable form, for moving them
+// between Stores and for backups. It is a gzipped tar file. For each
+// snippet, ID.json holds its metadata, an archiveMeta, and is followed
+// by ID.txtar holding its Body, unless the body was deleted by a
+// removal. After the snippets, m/ID.json holds each mutable snippet,
+// an archiveMutable; its revisions are archived as snippets. The
+// audit log of removals is not archived.
+
+// archiveMeta is the JSON metadata of a snippet in an archive.
+type archiveMeta struct {
+	ID        string
+	Created   time.Time `json:",omitzero"`
+	Toolchain string    `json:",omitempty"`
+	Size      int       `json:",omitempty"`
+	NumFiles  int       `json:",omitempty"`
+	Parent    string    `json:",omitempty"`
+	Removed   time.Time `json:",omitzero"`
+}
+
+// archiveMutable is the JSON of a mutable snippet in an archive.
+type archiveMutable struct {
+	ID        string
+	Head      string
+	TokenHash []byte
+	Created   time.Time
+	Updated   time.Time
+	Removed   time.Time `json:",omitzero"`
+}
+
+// mutablePrefix is the directory of mutable snippets in an archive.
+const mutablePrefix = "m/"
+
+// Export writes an archive of all the snippets, mutable or not, in st
+// to w, and returns how many it wrote. Snippets that st can't read are
+// left out, and reported to skip.
+func Export(ctx context.Context, st Store, w io.Writer, skip func(name string, err error)) (int, error) {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	n := 0
+	err := st.ListSnippets(ctx, func(id string, snip *Snippet) error {
+		if !ValidID(id) {
+			return fmt.Errorf("snippet with invalid ID %q", id)
+		}
+		meta, err := json.Marshal(&archiveMeta{
+			ID:        id,
+			Created:   snip.Created,
+			Toolchain: snip.Toolchain,
+			Size:      snip.Size,
+			NumFiles:  snip.NumFiles,
+			Parent:    snip.Parent,
+			Removed:   snip.Removed,
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, id+".json", append(meta, '\n')); err != nil {
+			return err
+		}
+		if snip.Body != nil || snip.Removed.IsZero() {
+			if err := writeTarFile(tw, id+".txtar", snip.Body); err != nil {
+				return err
+			}
+		}
+		n++
+		return nil
+	}, skip)
+	if err != nil {
+		return n, err
+	}
+	err = st.ListMutables(ctx, func(id string, m *Mutable) error {
+		if !ValidID(id) {
+			return fmt.Errorf("mutable snippet with invalid ID %q", id)
+		}
+		data, err := json.Marshal(&archiveMutable{
+			ID:        id,
+			Head:      m.Head,
+			TokenHash: m.TokenHash,
+			Created:   m.Created,
+			Updated:   m.Updated,
+			Removed:   m.Removed,
+		})
+		if err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, mutablePrefix+id+".json", append(data, '\n')); err != nil {
+			return err
+		}
+		n++
+		return nil
+	}, skip)
+	if err != nil {
+		return n, err
+	}
+	if err := tw.Close(); err != nil {
+		return n, err
+	}
+	return n, zw.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// Import puts the snippets, mutable or not, in the archive r into st,
+// and returns how many it put. A snippet whose body doesn't have the
+// ID it's archived under is not put, and is reported to skip; so is
+// one that st refuses, and a mutable snippet whose current revision
+// isn't in st. Other errors stop the import.
+func Import(ctx context.Context, st Store, r io.Reader, skip func(id string, err error)) (int, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	tr := tar.NewReader(zr)
+	n := 0
+	var pending *archiveMeta // read, but waiting for its body
+	flush := func(body []byte) error {
+		m := pending
+		pending = nil
+		snip := &Snippet{
+			Body:      body,
+			Created:   m.Created,
+			Toolchain: m.Toolchain,
+			Size:      m.Size,
+			NumFiles:  m.NumFiles,
+			Parent:    m.Parent,
+			Removed:   m.Removed,
+		}
+		// Only a removed snippet may lack the body its ID comes from.
+		if body == nil && m.Removed.IsZero() {
+			skip(m.ID, errors.New("no body"))
+			return nil
+		}
+		if body != nil {
+			if id := snip.ID(); id != m.ID {
+				skip(m.ID, fmt.Errorf("body has ID %s", id))
+				return nil
+			}
+		}
+		if err := st.PutSnippet(ctx, m.ID, snip); err != nil {
+			if errors.Is(err, ErrRemoved) {
+				skip(m.ID, err)
+				return nil
+			}
+			return fmt.Errorf("putting snippet %s: %w", m.ID, err)
+		}
+		n++
+		return nil
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return n, err
+		}
+		if pending != nil && strings.HasPrefix(hdr.Name, mutablePrefix) {
+			if err := flush(nil); err != nil {
+				return n, err
+			}
+		}
+		id, ext := strings.TrimSuffix(hdr.Name, path.Ext(hdr.Name)), path.Ext(hdr.Name)
+		if mid, ok := strings.CutPrefix(id, mutablePrefix); ok && ext == ".json" {
+			m := new(archiveMutable)
+			if err := json.Unmarshal(data, m); err != nil {
+				return n, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			if m.ID != mid || !ValidID(mid) {
+				return n, fmt.Errorf("%s: mutable snippet %q", hdr.Name, m.ID)
+			}
+			if err := st.GetSnippet(ctx, m.Head, new(Snippet)); err != nil {
+				skip(m.ID, fmt.Errorf("revision %s: %w", m.Head, err))
+				continue
+			}
+			err := st.UpdateMutable(ctx, m.ID, func(cur *Mutable) error {
+				*cur = Mutable{Head: m.Head, TokenHash: m.TokenHash, Created: m.Created, Updated: m.Updated, Removed: m.Removed}
+				return nil
+			})
+			if err != nil {
+				return n, fmt.Errorf("putting mutable snippet %s: %w", m.ID, err)
+			}
+			n++
+			continue
+		}
+		switch ext {
+		case ".json":
+			if pending != nil {
+				if err := flush(nil); err != nil {
+					return n, err
+				}
+			}
+			m := new(archiveMeta)
+			if err := json.Unmarshal(data, m); err != nil {
+				return n, fmt.Errorf("%s: %w", hdr.Name, err)
+			}
+			if m.ID != id || !ValidID(id) {
+				return n, fmt.Errorf("%s: metadata of snippet %q", hdr.Name, m.ID)
+			}
+			pending = m
+		case ".txtar":
+			if pending == nil || pending.ID != id {
+				return n, fmt.Errorf("%s: not after %s.json", hdr.Name, id)
+			}
+			if err := flush(data); err != nil {
+				return n, err
+			}
+		default:
+			return n, fmt.Errorf("unexpected file %s in archive", hdr.Name)
+		}
+	}
+	if pending != nil {
+		if err := flush(nil); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
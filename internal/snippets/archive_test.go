@@ -0,0 +1,133 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package snippets
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestArchive(t *testing.T) {
+	ctx := context.Background()
+	src := &MemStore{}
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	hello := &Snippet{Body: []byte("package main\n"), Created: created, Toolchain: "stable", Size: 13, NumFiles: 1, Parent: "N_M_YelfGeR"}
+	legacy := &Snippet{Body: []byte("-- a.go --\n-- b.go --\n")}
+	blocked := &Snippet{Body: []byte("spam")}
+	for _, snip := range []*Snippet{hello, legacy, blocked} {
+		if err := src.PutSnippet(ctx, snip.ID(), snip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, rm := range []*Removal{
+		{ID: blocked.ID(), Action: Block, Time: created},
+		{ID: "deleted", Action: Delete, Time: created},
+	} {
+		if err := src.RemoveSnippet(ctx, rm.ID, rm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mutable, mutableID, _ := NewMutable(hello.ID())
+	mutable.Removed = mutable.Created.Add(time.Hour)
+	if err := src.UpdateMutable(ctx, mutableID, func(m *Mutable) error { *m = *mutable; return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	exportSkip := func(name string, err error) { t.Errorf("Export skipped %s: %v", name, err) }
+	var archive bytes.Buffer
+	n, err := Export(ctx, src, &archive, exportSkip)
+	if err != nil || n != 5 {
+		t.Fatalf("Export = %d, %v; want 5, nil", n, err)
+	}
+
+	dst, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	skip := func(id string, err error) { t.Errorf("Import skipped %s: %v", id, err) }
+	if n, err := Import(ctx, dst, bytes.NewReader(archive.Bytes()), skip); err != nil || n != 5 {
+		t.Fatalf("Import = %d, %v; want 5, nil", n, err)
+	}
+	for _, id := range []string{hello.ID(), legacy.ID(), blocked.ID(), "deleted"} {
+		var want, got Snippet
+		if err := src.GetSnippet(ctx, id, &want); err != nil {
+			t.Fatal(err)
+		}
+		if err := dst.GetSnippet(ctx, id, &got); err != nil {
+			t.Errorf("GetSnippet(%s) after import: %v", id, err)
+			continue
+		}
+		if diff := cmp.Diff(&want, &got); diff != "" {
+			t.Errorf("snippet %s after import mismatch (-want +got):\n%s", id, diff)
+		}
+	}
+	var got Mutable
+	if err := dst.GetMutable(ctx, mutableID, &got); err != nil {
+		t.Errorf("GetMutable(%s) after import: %v", mutableID, err)
+	} else if diff := cmp.Diff(mutable, &got); diff != "" {
+		t.Errorf("mutable snippet %s after import mismatch (-want +got):\n%s", mutableID, diff)
+	}
+
+	// And back again.
+	if n, err := Export(ctx, dst, io.Discard, exportSkip); err != nil || n != 5 {
+		t.Errorf("Export of imported snippets = %d, %v; want 5, nil", n, err)
+	}
+}
+
+func TestImportMismatchedID(t *testing.T) {
+	var archive bytes.Buffer
+	zw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(zw)
+	for _, f := range []struct{ name, data string }{
+		{"N_M_YelfGeR.json", `{"ID":"N_M_YelfGeR"}`},
+		{"N_M_YelfGeR.txtar", "Snippy McSnipface"},
+		{"wrong.json", `{"ID":"wrong"}`},
+		{"wrong.txtar", "Snippy McSnipface"},
+		{"nobody.json", `{"ID":"nobody"}`},
+		{"m/q3JxV0dLwHbe.json", `{"ID":"q3JxV0dLwHbe","Head":"N_M_YelfGeR"}`},
+		{"m/orphan.json", `{"ID":"orphan","Head":"missing"}`},
+	} {
+		if err := writeTarFile(tw, f.name, []byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	zw.Close()
+
+	st := &MemStore{}
+	var skipped []string
+	n, err := Import(context.Background(), st, &archive, func(id string, err error) { skipped = append(skipped, id) })
+	if err != nil || n != 2 {
+		t.Errorf("Import = %d, %v; want 2, nil", n, err)
+	}
+	if want := []string{"wrong", "nobody", "orphan"}; !cmp.Equal(skipped, want) {
+		t.Errorf("Import skipped %q; want %q", skipped, want)
+	}
+	if err := st.GetSnippet(context.Background(), "wrong", new(Snippet)); err == nil {
+		t.Errorf("Import put a snippet whose body has another ID")
+	}
+}
+
+func TestImportOutOfOrder(t *testing.T) {
+	var archive bytes.Buffer
+	zw := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(zw)
+	writeTarFile(tw, "N_M_YelfGeR.txtar", []byte("Snippy McSnipface"))
+	tw.Close()
+	zw.Close()
+	_, err := Import(context.Background(), &MemStore{}, &archive, func(string, error) {})
+	if err == nil || errors.Is(err, ErrRemoved) {
+		t.Errorf("Import of a body without metadata = %v; want an error", err)
+	}
+}
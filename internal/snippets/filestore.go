@@ -0,0 +1,546 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package snippets
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/datastore"
+)
+
+// A FileStore is a Store that keeps snippets in a directory, for
+// self-hosted playgrounds without Cloud Datastore.
+//
+// Each snippet is a file named by the SHA-256 of its ID, so that IDs
+// differing only in case don't collide on case-insensitive file
+// systems, under two levels of directories named by the start of that
+// hash. A file holds one line: the snippet as JSON, prefixed by its
+// SHA-256 so that corruption is detected when it's read. Files are
+// replaced atomically by renaming, so readers never see a partial
+// write. The audit log of removals is removals.log, a line per
+// removal in the same form.
+//
+// Forks are indexed by a file per parent under forks, named like the
+// parent's snippet file, which lists the IDs of its forks a line at a
+// time. An ID is added before its snippet is written, so the index
+// may list IDs that aren't forks, but never misses one; Forks checks
+// each. Mutable snippets are files under mutable, in the same form as
+// snippets.
+//
+// A FileStore serializes its writes by locking the file lock, so that
+// other processes, such as snippettool, may use its directory at the
+// same time.
+type FileStore struct {
+	dir   string
+	mu    sync.Mutex // held while updating snippets or the audit log
+	lockf *os.File   // the lock file, locked while mu is held
+}
+
+// ErrCorrupt is returned for a snippet file or audit log entry whose
+// checksum doesn't match its contents.
+var ErrCorrupt = errors.New("checksum mismatch")
+
+// fileSnippet is the JSON in a snippet's file.
+type fileSnippet struct {
+	ID      string
+	Snippet *Snippet
+}
+
+// fileMutable is the JSON in a mutable snippet's file.
+type fileMutable struct {
+	ID      string
+	Mutable *Mutable
+}
+
+// NewFileStore returns a FileStore that keeps snippets in dir,
+// creating it if necessary.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "snippets"), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "lock"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileStore{dir: dir, lockf: f}
+	if err := s.lock(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	err = repairLog(filepath.Join(dir, "removals.log"))
+	s.unlock()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// lock serializes updates to s, with other goroutines and with other
+// processes.
+func (s *FileStore) lock() error {
+	s.mu.Lock()
+	if err := lockFile(s.lockf); err != nil {
+		s.mu.Unlock()
+		return fmt.Errorf("locking %s: %w", s.lockf.Name(), err)
+	}
+	return nil
+}
+
+func (s *FileStore) unlock() {
+	unlockFile(s.lockf)
+	s.mu.Unlock()
+}
+
+// repairLog removes a final line without a newline from the named
+// log. Such a line was cut off while being written, and the change
+// it recorded wasn't made.
+func repairLog(name string) error {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if n := bytes.LastIndexByte(data, '\n') + 1; n < len(data) {
+		return os.Truncate(name, int64(n))
+	}
+	return nil
+}
+
+// path returns the name of the file holding the snippet id.
+func (s *FileStore) path(id string) string {
+	return s.indexPath("snippets", id)
+}
+
+// indexPath returns the name of the file for the snippet id in the
+// directory kind: "snippets", "forks" or "mutable".
+func (s *FileStore) indexPath(kind, id string) string {
+	sum := sha256.Sum256([]byte(id))
+	h := hex.EncodeToString(sum[:])
+	return filepath.Join(s.dir, kind, h[:2], h[2:4], h)
+}
+
+func (s *FileStore) PutSnippet(_ context.Context, id string, snip *Snippet) error {
+	if !ValidID(id) {
+		return fmt.Errorf("invalid snippet ID %q", id)
+	}
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+	old, err := s.read(id)
+	switch {
+	case errors.Is(err, datastore.ErrNoSuchEntity):
+		old = &Snippet{}
+	case err != nil:
+		return err
+	case !old.Removed.IsZero():
+		return ErrRemoved
+	}
+	snip = keepCreated(snip, old)
+	if snip.Parent != "" && snip.Parent != old.Parent {
+		if err := s.appendFork(snip.Parent, id); err != nil {
+			return err
+		}
+	}
+	return s.write(id, snip)
+}
+
+func (s *FileStore) GetSnippet(_ context.Context, id string, snip *Snippet) error {
+	v, err := s.read(id)
+	if err != nil {
+		return err
+	}
+	*snip = *v
+	return nil
+}
+
+func (s *FileStore) ListSnippets(_ context.Context, fn func(id string, snip *Snippet) error, skip func(name string, err error)) error {
+	return filepath.WalkDir(filepath.Join(s.dir, "snippets"), func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return err
+		}
+		fsnip, err := readSnippetFile(name)
+		if errors.Is(err, ErrCorrupt) {
+			skip(name, err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fn(fsnip.ID, fsnip.Snippet)
+	})
+}
+
+func (s *FileStore) Forks(_ context.Context, id string, limit int) ([]string, error) {
+	if !ValidID(id) {
+		return nil, nil
+	}
+	data, err := os.ReadFile(s.indexPath("forks", id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// A final line without a newline was cut off while being
+	// written, and its snippet wasn't.
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+	var ids []string
+	seen := make(map[string]bool)
+	for line := range strings.Lines(string(data)) {
+		if len(ids) == limit {
+			break
+		}
+		fork := strings.TrimSuffix(line, "\n")
+		if seen[fork] {
+			continue
+		}
+		seen[fork] = true
+		snip, err := s.read(fork)
+		if errors.Is(err, datastore.ErrNoSuchEntity) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if snip.Parent == id {
+			ids = append(ids, fork)
+		}
+	}
+	return ids, nil
+}
+
+func (s *FileStore) GetMutable(_ context.Context, id string, m *Mutable) error {
+	v, err := s.readMutable(id)
+	if err != nil {
+		return err
+	}
+	*m = *v
+	return nil
+}
+
+func (s *FileStore) UpdateMutable(_ context.Context, id string, fn func(m *Mutable) error) error {
+	if !ValidID(id) {
+		return fmt.Errorf("invalid mutable snippet ID %q", id)
+	}
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+	m, err := s.readMutable(id)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		m, err = &Mutable{}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := fn(m); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(&fileMutable{ID: id, Mutable: m})
+	if err != nil {
+		return err
+	}
+	return writeLine(s.indexPath("mutable", id), payload)
+}
+
+// readMutable returns the mutable snippet id, or
+// datastore.ErrNoSuchEntity if there isn't one.
+func (s *FileStore) readMutable(id string) (*Mutable, error) {
+	if !ValidID(id) {
+		return nil, datastore.ErrNoSuchEntity
+	}
+	name := s.indexPath("mutable", id)
+	fm, err := readMutableFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if fm.ID != id {
+		return nil, fmt.Errorf("mutable snippet %s in %s: %w", id, name, ErrCorrupt)
+	}
+	return fm.Mutable, nil
+}
+
+func (s *FileStore) ListMutables(_ context.Context, fn func(id string, m *Mutable) error, skip func(name string, err error)) error {
+	err := filepath.WalkDir(filepath.Join(s.dir, "mutable"), func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return err
+		}
+		fm, err := readMutableFile(name)
+		if errors.Is(err, ErrCorrupt) {
+			skip(name, err)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fn(fm.ID, fm.Mutable)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		// No mutable snippet has been created.
+		return nil
+	}
+	return err
+}
+
+// readMutableFile reads the named mutable snippet file. If there is
+// none, it returns datastore.ErrNoSuchEntity.
+func readMutableFile(name string) (*fileMutable, error) {
+	payload, err := readLine(name)
+	if err != nil {
+		return nil, err
+	}
+	var fm fileMutable
+	if err := json.Unmarshal(payload, &fm); err != nil {
+		return nil, fmt.Errorf("mutable snippet in %s: %w", name, err)
+	}
+	if fm.Mutable == nil {
+		return nil, fmt.Errorf("mutable snippet in %s: %w", name, ErrCorrupt)
+	}
+	return &fm, nil
+}
+
+func (s *FileStore) RemoveSnippet(_ context.Context, id string, rm *Removal) error {
+	if !ValidID(id) {
+		return fmt.Errorf("invalid snippet ID %q", id)
+	}
+	if err := s.lock(); err != nil {
+		return err
+	}
+	defer s.unlock()
+	old, err := s.read(id)
+	if errors.Is(err, datastore.ErrNoSuchEntity) {
+		old, err = &Snippet{}, nil
+	}
+	if err != nil {
+		// A corrupt snippet can still be removed.
+		if !errors.Is(err, ErrCorrupt) {
+			return err
+		}
+		old = &Snippet{}
+	}
+	// Record the removal first, so that no snippet is removed
+	// without a record of who did it.
+	if err := s.appendRemoval(rm); err != nil {
+		return err
+	}
+	return s.write(id, tombstone(old, rm))
+}
+
+func (s *FileStore) Removals(_ context.Context, limit int) ([]*Removal, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, "removals.log"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rms []*Removal
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(nil, 1<<20)
+	for n := 1; sc.Scan(); n++ {
+		payload, err := checkSum(sc.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("removals.log:%d: %w", n, err)
+		}
+		rm := new(Removal)
+		if err := json.Unmarshal(payload, rm); err != nil {
+			return nil, fmt.Errorf("removals.log:%d: %w", n, err)
+		}
+		rms = append(rms, rm)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	slices.Reverse(rms)
+	return rms[:min(limit, len(rms))], nil
+}
+
+// read returns the snippet id, or datastore.ErrNoSuchEntity if there
+// isn't one.
+func (s *FileStore) read(id string) (*Snippet, error) {
+	if !ValidID(id) {
+		return nil, datastore.ErrNoSuchEntity
+	}
+	fsnip, err := readSnippetFile(s.path(id))
+	if err != nil {
+		return nil, err
+	}
+	if fsnip.ID != id {
+		return nil, fmt.Errorf("snippet %s in %s: %w", id, s.path(id), ErrCorrupt)
+	}
+	return fsnip.Snippet, nil
+}
+
+// readSnippetFile reads the named snippet file. If there is none, it
+// returns datastore.ErrNoSuchEntity.
+func readSnippetFile(name string) (*fileSnippet, error) {
+	payload, err := readLine(name)
+	if err != nil {
+		return nil, err
+	}
+	var fsnip fileSnippet
+	if err := json.Unmarshal(payload, &fsnip); err != nil {
+		return nil, fmt.Errorf("snippet in %s: %w", name, err)
+	}
+	if fsnip.Snippet == nil {
+		return nil, fmt.Errorf("snippet in %s: %w", name, ErrCorrupt)
+	}
+	return &fsnip, nil
+}
+
+// readLine returns the payload of the named file, which holds a line
+// as written by writeLine. If there is no file, it returns
+// datastore.ErrNoSuchEntity.
+func readLine(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, datastore.ErrNoSuchEntity
+	}
+	if err != nil {
+		return nil, err
+	}
+	payload, err := checkSum(bytes.TrimSuffix(data, []byte("\n")))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return payload, nil
+}
+
+// write atomically replaces the file of the snippet id with snip.
+func (s *FileStore) write(id string, snip *Snippet) error {
+	payload, err := json.Marshal(&fileSnippet{ID: id, Snippet: snip})
+	if err != nil {
+		return err
+	}
+	return writeLine(s.path(id), payload)
+}
+
+// writeLine atomically replaces the named file with a line of payload
+// and its checksum, creating its directory if necessary.
+func writeLine(name string, payload []byte) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // fails harmlessly after the rename
+	if _, err := f.Write(sumLine(payload)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), name); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// appendRemoval appends rm to the audit log.
+func (s *FileStore) appendRemoval(rm *Removal) error {
+	payload, err := json.Marshal(rm)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, "removals.log"), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(sumLine(payload)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// appendFork adds fork to the index of the forks of the snippet
+// parent.
+func (s *FileStore) appendFork(parent, fork string) error {
+	if !ValidID(parent) {
+		return fmt.Errorf("invalid parent snippet ID %q", parent)
+	}
+	name := s.indexPath("forks", parent)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	if err := repairLog(name); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%s\n", fork); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sumLine returns a line of the hex SHA-256 of payload, a space and
+// payload, which must not contain a newline.
+func sumLine(payload []byte) []byte {
+	sum := sha256.Sum256(payload)
+	return fmt.Appendf(nil, "%x %s\n", sum, payload)
+}
+
+// checkSum checks that line, without its newline, is the hex SHA-256
+// of a payload, a space and the payload, and returns the payload.
+func checkSum(line []byte) ([]byte, error) {
+	h, payload, ok := bytes.Cut(line, []byte(" "))
+	want := sha256.Sum256(payload)
+	var got [sha256.Size]byte
+	if !ok || hex.DecodedLen(len(h)) != len(got) {
+		return nil, ErrCorrupt
+	}
+	if _, err := hex.Decode(got[:], h); err != nil || got != want {
+		return nil, ErrCorrupt
+	}
+	return payload, nil
+}
+
+// syncDir flushes the directory dir, so that a rename in it is
+// durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
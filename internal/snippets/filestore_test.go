@@ -0,0 +1,293 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package snippets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.GetSnippet(ctx, "missing", new(Snippet)); err != datastore.ErrNoSuchEntity {
+		t.Errorf("GetSnippet(missing) = %v; want %v", err, datastore.ErrNoSuchEntity)
+	}
+	if err := db.GetSnippet(ctx, "../../etc/passwd", new(Snippet)); err != datastore.ErrNoSuchEntity {
+		t.Errorf("GetSnippet(../../etc/passwd) = %v; want %v", err, datastore.ErrNoSuchEntity)
+	}
+
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	snip := &Snippet{Body: []byte("package main\n"), Created: created, Toolchain: "stable", Size: 13, NumFiles: 1}
+	if err := db.PutSnippet(ctx, "abc", snip); err != nil {
+		t.Fatalf("PutSnippet: %v", err)
+	}
+	// IDs differing only in case are different snippets.
+	if err := db.PutSnippet(ctx, "ABC", &Snippet{Body: []byte("other")}); err != nil {
+		t.Fatalf("PutSnippet: %v", err)
+	}
+	// Sharing again keeps the original metadata.
+	if err := db.PutSnippet(ctx, "abc", &Snippet{Body: snip.Body, Created: created.Add(time.Hour), Toolchain: "gotip", Size: 13, NumFiles: 1}); err != nil {
+		t.Fatalf("PutSnippet: %v", err)
+	}
+
+	// Snippets survive reopening the store.
+	db, err = NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Snippet
+	if err := db.GetSnippet(ctx, "abc", &got); err != nil {
+		t.Fatalf("GetSnippet(abc): %v", err)
+	}
+	if diff := cmp.Diff(snip, &got); diff != "" {
+		t.Errorf("GetSnippet(abc) mismatch (-want +got):\n%s", diff)
+	}
+	if err := db.GetSnippet(ctx, "ABC", &got); err != nil || string(got.Body) != "other" {
+		t.Errorf("GetSnippet(ABC) = %q, %v; want %q", got.Body, err, "other")
+	}
+
+	rm := &Removal{ID: "abc", Action: Delete, Admin: "alice", Reason: "leak", Time: created}
+	if err := db.RemoveSnippet(ctx, "abc", rm); err != nil {
+		t.Fatalf("RemoveSnippet: %v", err)
+	}
+	if err := db.RemoveSnippet(ctx, "unshared", &Removal{ID: "unshared", Action: Block, Admin: "bob", Reason: "spam", Time: created}); err != nil {
+		t.Fatalf("RemoveSnippet: %v", err)
+	}
+	if err := db.GetSnippet(ctx, "abc", &got); err != nil || got.Body != nil || !got.Removed.Equal(created) {
+		t.Errorf("GetSnippet of deleted snippet = %+v, %v; want a tombstone", got, err)
+	}
+	if err := db.PutSnippet(ctx, "unshared", &Snippet{Body: []byte("spam")}); !errors.Is(err, ErrRemoved) {
+		t.Errorf("PutSnippet of blocked snippet = %v; want %v", err, ErrRemoved)
+	}
+	rms, err := db.Removals(ctx, 10)
+	if err != nil {
+		t.Fatalf("Removals: %v", err)
+	}
+	if len(rms) != 2 || rms[0].ID != "unshared" || !cmp.Equal(rms[1], rm) {
+		t.Errorf("Removals = %+v; want both removals, newest first", rms)
+	}
+}
+
+func TestFileStoreForks(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for _, f := range []struct{ id, parent string }{
+		{"root", ""},
+		{"fork1", "root"},
+		{"fork2", "root"},
+		{"grandchild", "fork1"},
+		{"fork1", "fork2"}, // shared again; keeps its parent
+	} {
+		if err := db.PutSnippet(ctx, f.id, &Snippet{Created: created, Parent: f.parent}); err != nil {
+			t.Fatalf("PutSnippet(%s): %v", f.id, err)
+		}
+	}
+	// A fork whose line in the index was cut off is forgotten, but
+	// doesn't hide later ones.
+	idx, err := os.OpenFile(db.indexPath("forks", "root"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idx.WriteString("torn")
+	idx.Close()
+	if err := db.PutSnippet(ctx, "fork3", &Snippet{Created: created, Parent: "root"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		id    string
+		limit int
+		want  []string
+	}{
+		{"root", 10, []string{"fork1", "fork2", "fork3"}},
+		{"root", 2, []string{"fork1", "fork2"}},
+		{"fork1", 10, []string{"grandchild"}},
+		{"fork2", 10, nil},
+		{"missing", 10, nil},
+	} {
+		got, err := db.Forks(ctx, tt.id, tt.limit)
+		if err != nil || !cmp.Equal(got, tt.want) {
+			t.Errorf("Forks(%s, %d) = %q, %v; want %q", tt.id, tt.limit, got, err, tt.want)
+		}
+	}
+}
+
+func TestFileStoreMutable(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.GetMutable(ctx, "missing", new(Mutable)); err != datastore.ErrNoSuchEntity {
+		t.Errorf("GetMutable(missing) = %v; want %v", err, datastore.ErrNoSuchEntity)
+	}
+	list := func() []string {
+		t.Helper()
+		var ids []string
+		if err := db.ListMutables(ctx, func(id string, _ *Mutable) error {
+			ids = append(ids, id)
+			return nil
+		}, func(name string, err error) {
+			t.Errorf("ListMutables skipped %s: %v", name, err)
+		}); err != nil {
+			t.Fatalf("ListMutables: %v", err)
+		}
+		return ids
+	}
+	if ids := list(); len(ids) != 0 {
+		t.Errorf("ListMutables of an empty store = %q; want none", ids)
+	}
+
+	m, id, token := NewMutable("rev1")
+	if err := db.UpdateMutable(ctx, id, func(cur *Mutable) error {
+		if cur.Head != "" {
+			t.Errorf("UpdateMutable of new snippet called fn with %+v; want zero", cur)
+		}
+		*cur = *m
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateMutable: %v", err)
+	}
+	errStop := errors.New("stop")
+	if err := db.UpdateMutable(ctx, id, func(cur *Mutable) error {
+		cur.Head = "rev2"
+		return errStop
+	}); err != errStop {
+		t.Errorf("UpdateMutable = %v; want %v", err, errStop)
+	}
+
+	// Mutable snippets survive reopening the store.
+	db, err = NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Mutable
+	if err := db.GetMutable(ctx, id, &got); err != nil {
+		t.Fatalf("GetMutable: %v", err)
+	}
+	if diff := cmp.Diff(m, &got); diff != "" {
+		t.Errorf("GetMutable mismatch (-want +got):\n%s", diff)
+	}
+	if !got.CheckToken(token) || got.CheckToken(token+"x") {
+		t.Errorf("CheckToken doesn't accept only the edit token")
+	}
+	if ids := list(); !slices.Equal(ids, []string{id}) {
+		t.Errorf("ListMutables = %q; want %q", ids, []string{id})
+	}
+}
+
+func TestFileStoreCorruption(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.PutSnippet(ctx, "abc", &Snippet{Body: []byte("package main\n")}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(db.path("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-10] ^= 1
+	if err := os.WriteFile(db.path("abc"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.GetSnippet(ctx, "abc", new(Snippet)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("GetSnippet of corrupt snippet = %v; want %v", err, ErrCorrupt)
+	}
+
+	// Listing skips the corrupt snippet, and goes on to the others.
+	if err := db.PutSnippet(ctx, "ghi", &Snippet{Body: []byte("y")}); err != nil {
+		t.Fatal(err)
+	}
+	var ids, skipped []string
+	err = db.ListSnippets(ctx, func(id string, _ *Snippet) error {
+		ids = append(ids, id)
+		return nil
+	}, func(name string, err error) {
+		if !errors.Is(err, ErrCorrupt) {
+			t.Errorf("ListSnippets skipped %s: %v; want %v", name, err, ErrCorrupt)
+		}
+		skipped = append(skipped, name)
+	})
+	if err != nil || !slices.Equal(ids, []string{"ghi"}) || !slices.Equal(skipped, []string{db.path("abc")}) {
+		t.Errorf("ListSnippets = %q, skipping %q, %v; want %q, skipping %q", ids, skipped, err, []string{"ghi"}, []string{db.path("abc")})
+	}
+
+	// A snippet file moved to another ID's name is detected too.
+	if err := db.PutSnippet(ctx, "def", &Snippet{Body: []byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(db.path("def"), db.path("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.GetSnippet(ctx, "abc", new(Snippet)); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("GetSnippet of misplaced snippet = %v; want %v", err, ErrCorrupt)
+	}
+
+	// A removal cut off while being logged is dropped on reopening.
+	if err := db.RemoveSnippet(ctx, "abc", &Removal{ID: "abc", Action: Delete, Admin: "alice", Reason: "corrupt"}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, "removals.log"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`0123 {"ID":"x"`)
+	f.Close()
+	if db, err = NewFileStore(dir); err != nil {
+		t.Fatal(err)
+	}
+	if rms, err := db.Removals(ctx, 10); err != nil || len(rms) != 1 {
+		t.Errorf("Removals after a torn write = %+v, %v; want the one complete removal", rms, err)
+	}
+}
+
+func TestFileStoreConcurrent(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Go(func() {
+			for j := range 20 {
+				id := fmt.Sprint("id", j%4)
+				if err := db.PutSnippet(ctx, id, &Snippet{Body: []byte(fmt.Sprint(i, j))}); err != nil {
+					t.Errorf("PutSnippet: %v", err)
+				}
+				if err := db.GetSnippet(ctx, id, new(Snippet)); err != nil {
+					t.Errorf("GetSnippet: %v", err)
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
@@ -0,0 +1,15 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package snippets
+
+import "os"
+
+// lockFile does nothing: only one process may use a FileStore's
+// directory at a time on this system.
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
@@ -0,0 +1,43 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package snippets
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFileStoreLock(t *testing.T) {
+	// Two stores for one directory stand in for two processes.
+	dir := t.TempDir()
+	db1, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db2, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db1.lock(); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- db2.PutSnippet(context.Background(), "abc", &Snippet{Body: []byte("x")})
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("PutSnippet returned %v while another store held the lock", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	db1.unlock()
+	if err := <-done; err != nil {
+		t.Fatalf("PutSnippet after the lock was released: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2011 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package snippets stores the playground's shared snippets.
+package snippets
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"time"
+
+	"golang.org/x/tools/txtar"
+)
+
+// This salt is not meant to be kept secret (it’s checked in after all). It’s
+// a tiny bit of paranoia to avoid whatever problems a collision may cause.
+const salt = "Go playground salt\n"
+
+// A Snippet is a shared program.
+type Snippet struct {
+	Body []byte `datastore:",noindex"` // golang.org/issues/23253
+
+	// The remaining fields are recorded when the snippet is first
+	// shared. They are zero for snippets shared before they existed.
+	Created   time.Time
+	Toolchain string // "stable", "goprev" or "gotip"
+	Size      int    // len(Body)
+	NumFiles  int    `datastore:",noindex"` // files in Body, as a txtar archive
+	Parent    string // ID of the snippet this was forked from, if any
+
+	// Removed, if non-zero, is when an admin removed the snippet. It
+	// is then not served, and can't be shared again.
+	Removed time.Time
+}
+
+// New returns a Snippet of body, shared now from toolchain by
+// editing the snippet parent, which may be empty.
+func New(body []byte, toolchain, parent string) *Snippet {
+	return &Snippet{
+		Body:      body,
+		Created:   time.Now().UTC(),
+		Toolchain: toolchain,
+		Size:      len(body),
+		NumFiles:  NumFiles(body),
+		Parent:    parent,
+	}
+}
+
+// NumFiles returns the number of files in body, as the playground
+// would split it, but without checking that they're valid.
+func NumFiles(body []byte) int {
+	a := txtar.Parse(body)
+	n := len(a.Files)
+	if len(bytes.TrimSpace(a.Comment)) > 0 {
+		n++ // the implicit prog.go
+	}
+	return n
+}
+
+// ID returns the ID of s, derived from its Body.
+func (s *Snippet) ID() string {
+	h := sha256.New()
+	io.WriteString(h, salt)
+	h.Write(s.Body)
+	sum := h.Sum(nil)
+	b := make([]byte, base64.URLEncoding.EncodedLen(len(sum)))
+	base64.URLEncoding.Encode(b, sum)
+	// Web sites don’t always linkify a trailing underscore, making it seem like
+	// the link is broken. If there is an underscore at the end of the substring,
+	// extend it until there is not.
+	hashLen := 11
+	for hashLen <= len(b) && b[hashLen-1] == '_' {
+		hashLen++
+	}
+	return string(b)[:hashLen]
+}
+
+// ValidID reports whether id could be a snippet's ID: URL-safe
+// base64, as produced by Snippet.ID.
+func ValidID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// A Mutable is a snippet with a stable ID whose body can be replaced
+// by whoever holds its edit token. Each body is kept as an immutable
+// Snippet, a revision, whose Parent is the revision it replaced.
+type Mutable struct {
+	Head      string    // ID of the current revision
+	TokenHash []byte    `datastore:",noindex"` // SHA-256 of the edit token
+	Created   time.Time // when the mutable snippet was created
+	Updated   time.Time // when Head was last replaced
+	// Removed, if non-zero, is when an admin removed the mutable
+	// snippet. It is then neither served nor updated.
+	Removed time.Time
+}
+
+// NewMutable returns a new Mutable whose current revision is head,
+// with its random ID and edit token.
+func NewMutable(head string) (m *Mutable, id, token string) {
+	b := make([]byte, 9)
+	for id == "" || id[len(id)-1] == '_' { // as in Snippet.ID
+		rand.Read(b)
+		id = base64.URLEncoding.EncodeToString(b)
+	}
+	token = rand.Text()
+	now := time.Now().UTC()
+	return &Mutable{Head: head, TokenHash: hashToken(token), Created: now, Updated: now}, id, token
+}
+
+// CheckToken reports whether token is m's edit token.
+func (m *Mutable) CheckToken(token string) bool {
+	return subtle.ConstantTimeCompare(hashToken(token), m.TokenHash) == 1
+}
+
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
@@ -0,0 +1,359 @@
+// Copyright 2017 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package snippets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"google.golang.org/api/iterator"
+)
+
+// A Store stores snippets by ID.
+type Store interface {
+	// PutSnippet stores snip under id. If a snippet with metadata is
+	// already stored under id, as when the same body is shared again,
+	// its Created time, Toolchain and Parent are kept. If the snippet stored
+	// under id was removed, PutSnippet returns ErrRemoved.
+	PutSnippet(ctx context.Context, id string, snip *Snippet) error
+	// GetSnippet loads the snippet id into snip. If there is none, it
+	// returns datastore.ErrNoSuchEntity.
+	GetSnippet(ctx context.Context, id string, snip *Snippet) error
+	// ListSnippets calls fn for every snippet, including removed ones,
+	// in no particular order. It stops at the first error from fn,
+	// and returns it. A snippet that can't be read is reported to
+	// skip, with its ID or, if that can't be read either, where it's
+	// stored, and the listing goes on.
+	ListSnippets(ctx context.Context, fn func(id string, snip *Snippet) error, skip func(name string, err error)) error
+	// Forks returns the IDs of up to limit snippets whose Parent is
+	// id, in no particular order.
+	Forks(ctx context.Context, id string, limit int) ([]string, error)
+
+	// GetMutable loads the mutable snippet id into m. If there is
+	// none, it returns datastore.ErrNoSuchEntity.
+	GetMutable(ctx context.Context, id string, m *Mutable) error
+	// UpdateMutable atomically calls fn with the mutable snippet id,
+	// or a zero Mutable if there is none, and stores the result. If
+	// fn returns an error, nothing is stored and UpdateMutable
+	// returns it.
+	UpdateMutable(ctx context.Context, id string, fn func(m *Mutable) error) error
+	// ListMutables calls fn for every mutable snippet, in no
+	// particular order. It stops at the first error from fn, and
+	// returns it. Like ListSnippets, it reports mutable snippets that
+	// can't be read to skip.
+	ListMutables(ctx context.Context, fn func(id string, m *Mutable) error, skip func(name string, err error)) error
+
+	// RemoveSnippet leaves a tombstone for the snippet id, which
+	// need not exist, as rm describes, and records rm in the audit
+	// log.
+	RemoveSnippet(ctx context.Context, id string, rm *Removal) error
+	// Removals returns up to limit of the most recent removals, newest
+	// first.
+	Removals(ctx context.Context, limit int) ([]*Removal, error)
+}
+
+// ErrRemoved is returned by PutSnippet for a snippet that
+// was removed.
+var ErrRemoved = errors.New("snippet was removed")
+
+// A Removal is an entry in the audit log of removed snippets.
+type Removal struct {
+	ID     string    // of the snippet
+	Action string    // Delete or Block
+	Admin  string    // who removed the snippet
+	Reason string    `datastore:",noindex"`
+	Time   time.Time // when
+	// Mutable is the ID of the mutable snippet whose current
+	// revision was ID, if the snippet was removed with it.
+	Mutable string `json:",omitempty"`
+}
+
+// Removal actions.
+const (
+	Delete = "delete" // discard the snippet's body
+	Block  = "block"  // keep the body, but don't serve it
+)
+
+// tombstone returns old, a snippet that may be zero, as it is after
+// rm. It keeps the metadata of old, and its Body unless rm deletes it.
+func tombstone(old *Snippet, rm *Removal) *Snippet {
+	s := *old
+	s.Removed = rm.Time
+	if rm.Action == Delete {
+		s.Body = nil
+	}
+	return &s
+}
+
+// Datastore is a Store in Cloud Datastore. Snippets are entities of
+// kind Snippet, removals of kind SnippetRemoval, and mutable snippets
+// of kind MutableSnippet.
+type Datastore struct {
+	Client *datastore.Client
+}
+
+func (s Datastore) PutSnippet(ctx context.Context, id string, snip *Snippet) error {
+	key := datastore.NameKey("Snippet", id, nil)
+	_, err := s.Client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var old Snippet
+		if err := tx.Get(key, &old); err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		if !old.Removed.IsZero() {
+			return ErrRemoved
+		}
+		_, err := tx.Put(key, keepCreated(snip, &old))
+		return err
+	})
+	return err
+}
+
+// keepCreated returns snip, which is replacing old, with old's
+// Created time, Toolchain and Parent if it has them.
+func keepCreated(snip, old *Snippet) *Snippet {
+	if old.Created.IsZero() {
+		return snip
+	}
+	s := *snip
+	s.Created, s.Toolchain, s.Parent = old.Created, old.Toolchain, old.Parent
+	return &s
+}
+
+func (s Datastore) GetSnippet(ctx context.Context, id string, snip *Snippet) error {
+	key := datastore.NameKey("Snippet", id, nil)
+	return s.Client.Get(ctx, key, snip)
+}
+
+func (s Datastore) ListSnippets(ctx context.Context, fn func(id string, snip *Snippet) error, _ func(string, error)) error {
+	it := s.Client.Run(ctx, datastore.NewQuery("Snippet"))
+	for {
+		snip := new(Snippet)
+		key, err := it.Next(snip)
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(key.Name, snip); err != nil {
+			return err
+		}
+	}
+}
+
+func (s Datastore) Forks(ctx context.Context, id string, limit int) ([]string, error) {
+	q := datastore.NewQuery("Snippet").FilterField("Parent", "=", id).KeysOnly().Limit(limit)
+	keys, err := s.Client.GetAll(ctx, q, nil)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(keys))
+	for i, key := range keys {
+		ids[i] = key.Name
+	}
+	return ids, nil
+}
+
+func (s Datastore) GetMutable(ctx context.Context, id string, m *Mutable) error {
+	key := datastore.NameKey("MutableSnippet", id, nil)
+	return s.Client.Get(ctx, key, m)
+}
+
+func (s Datastore) UpdateMutable(ctx context.Context, id string, fn func(m *Mutable) error) error {
+	key := datastore.NameKey("MutableSnippet", id, nil)
+	_, err := s.Client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var m Mutable
+		if err := tx.Get(key, &m); err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		if err := fn(&m); err != nil {
+			return err
+		}
+		_, err := tx.Put(key, &m)
+		return err
+	})
+	return err
+}
+
+func (s Datastore) ListMutables(ctx context.Context, fn func(id string, m *Mutable) error, _ func(string, error)) error {
+	it := s.Client.Run(ctx, datastore.NewQuery("MutableSnippet"))
+	for {
+		m := new(Mutable)
+		key, err := it.Next(m)
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(key.Name, m); err != nil {
+			return err
+		}
+	}
+}
+
+func (s Datastore) RemoveSnippet(ctx context.Context, id string, rm *Removal) error {
+	key := datastore.NameKey("Snippet", id, nil)
+	_, err := s.Client.RunInTransaction(ctx, func(tx *datastore.Transaction) error {
+		var old Snippet
+		if err := tx.Get(key, &old); err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		if _, err := tx.Put(key, tombstone(&old, rm)); err != nil {
+			return err
+		}
+		_, err := tx.Put(datastore.IncompleteKey("SnippetRemoval", nil), rm)
+		return err
+	})
+	return err
+}
+
+func (s Datastore) Removals(ctx context.Context, limit int) ([]*Removal, error) {
+	var rms []*Removal
+	q := datastore.NewQuery("SnippetRemoval").Order("-Time").Limit(limit)
+	if _, err := s.Client.GetAll(ctx, q, &rms); err != nil {
+		return nil, err
+	}
+	return rms, nil
+}
+
+// MemStore is a Store backed by a map that should only be used for testing.
+type MemStore struct {
+	sync.RWMutex
+	m        map[string]*Snippet // key -> snippet
+	removals []*Removal          // oldest first
+	mutables map[string]*Mutable
+}
+
+func (s *MemStore) PutSnippet(_ context.Context, id string, snip *Snippet) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.m == nil {
+		s.m = map[string]*Snippet{}
+	}
+	v := *snip
+	v.Body = bytes.Clone(snip.Body)
+	if old, ok := s.m[id]; ok {
+		if !old.Removed.IsZero() {
+			return ErrRemoved
+		}
+		v = *keepCreated(&v, old)
+	}
+	s.m[id] = &v
+	return nil
+}
+
+func (s *MemStore) GetSnippet(_ context.Context, id string, snip *Snippet) error {
+	s.RLock()
+	defer s.RUnlock()
+	v, ok := s.m[id]
+	if !ok {
+		return datastore.ErrNoSuchEntity
+	}
+	*snip = *v
+	return nil
+}
+
+func (s *MemStore) ListSnippets(_ context.Context, fn func(id string, snip *Snippet) error, _ func(string, error)) error {
+	s.RLock()
+	ids := slices.Sorted(maps.Keys(s.m))
+	s.RUnlock()
+	for _, id := range ids {
+		var snip Snippet
+		if err := s.GetSnippet(context.Background(), id, &snip); err != nil {
+			return err
+		}
+		if err := fn(id, &snip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemStore) Forks(_ context.Context, id string, limit int) ([]string, error) {
+	s.RLock()
+	defer s.RUnlock()
+	var ids []string
+	for _, k := range slices.Sorted(maps.Keys(s.m)) {
+		if s.m[k].Parent == id && len(ids) < limit {
+			ids = append(ids, k)
+		}
+	}
+	return ids, nil
+}
+
+func (s *MemStore) GetMutable(_ context.Context, id string, m *Mutable) error {
+	s.RLock()
+	defer s.RUnlock()
+	v, ok := s.mutables[id]
+	if !ok {
+		return datastore.ErrNoSuchEntity
+	}
+	*m = *v
+	return nil
+}
+
+func (s *MemStore) UpdateMutable(_ context.Context, id string, fn func(m *Mutable) error) error {
+	s.Lock()
+	defer s.Unlock()
+	var v Mutable
+	if old, ok := s.mutables[id]; ok {
+		v = *old
+	}
+	if err := fn(&v); err != nil {
+		return err
+	}
+	if s.mutables == nil {
+		s.mutables = map[string]*Mutable{}
+	}
+	s.mutables[id] = &v
+	return nil
+}
+
+func (s *MemStore) ListMutables(_ context.Context, fn func(id string, m *Mutable) error, _ func(string, error)) error {
+	s.RLock()
+	ids := slices.Sorted(maps.Keys(s.mutables))
+	s.RUnlock()
+	for _, id := range ids {
+		var m Mutable
+		if err := s.GetMutable(context.Background(), id, &m); err != nil {
+			return err
+		}
+		if err := fn(id, &m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemStore) RemoveSnippet(_ context.Context, id string, rm *Removal) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.m == nil {
+		s.m = map[string]*Snippet{}
+	}
+	old, ok := s.m[id]
+	if !ok {
+		old = &Snippet{}
+	}
+	s.m[id] = tombstone(old, rm)
+	v := *rm
+	s.removals = append(s.removals, &v)
+	return nil
+}
+
+func (s *MemStore) Removals(_ context.Context, limit int) ([]*Removal, error) {
+	s.RLock()
+	defer s.RUnlock()
+	rms := slices.Clone(s.removals)
+	slices.Reverse(rms)
+	return rms[:min(limit, len(rms))], nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/datastore"
+	"golang.org/x/playground/internal/snippets"
+)
+
+// Limits on the snippets listed by /lineage/ID.
+const (
+	maxAncestors = 100
+	maxForks     = 100
+)
+
+// lineage is the JSON served by /lineage/ID.
+type lineage struct {
+	Snippet   *snippetMeta
+	Ancestors []*snippetMeta // the parent first
+	Forks     []*snippetMeta // the oldest first
+}
+
+// handleLineage serves the ancestors of a snippet, following each
+// one's Parent, and its direct forks. Removed ancestors are listed
+// by ID alone, but removed forks aren't listed.
+func (s *server) handleLineage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if r.Method == "OPTIONS" {
+		// This is likely a pre-flight CORS request.
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Requires GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/lineage/")
+	snip, ok := s.getLineageSnippet(w, r, id)
+	if !ok {
+		return
+	}
+	if snip == nil {
+		http.Error(w, "Snippet not found", http.StatusNotFound)
+		return
+	}
+	if !snip.Removed.IsZero() {
+		http.Error(w, "Snippet removed", http.StatusGone)
+		return
+	}
+	l := &lineage{Snippet: newSnippetMeta(id, snip), Ancestors: []*snippetMeta{}, Forks: []*snippetMeta{}}
+
+	// Snippets shared before their Created time was recorded can be
+	// given a parent by sharing them again, so guard against cycles.
+	seen := map[string]bool{id: true}
+	for p := snip.Parent; p != "" && !seen[p] && len(l.Ancestors) < maxAncestors; {
+		seen[p] = true
+		parent, ok := s.getLineageSnippet(w, r, p)
+		if !ok {
+			return
+		}
+		if parent == nil {
+			break
+		}
+		l.Ancestors = append(l.Ancestors, newSnippetMeta(p, parent))
+		p = parent.Parent
+	}
+
+	forks, err := s.db.Forks(r.Context(), id, maxForks)
+	if err != nil {
+		s.log.Errorf("listing forks of Snippet %s: %v", id, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	for _, f := range forks {
+		fork, ok := s.getLineageSnippet(w, r, f)
+		if !ok {
+			return
+		}
+		if fork != nil && fork.Removed.IsZero() {
+			l.Forks = append(l.Forks, newSnippetMeta(f, fork))
+		}
+	}
+	slices.SortFunc(l.Forks, func(a, b *snippetMeta) int {
+		return cmp.Or(a.Created.Compare(b.Created), strings.Compare(a.ID, b.ID))
+	})
+
+	s.writeJSONResponse(w, l, http.StatusOK)
+}
+
+// getLineageSnippet returns the snippet id, or nil if there is none.
+// If it fails to load the snippet, it replies with an error and
+// returns false.
+func (s *server) getLineageSnippet(w http.ResponseWriter, r *http.Request, id string) (*snippets.Snippet, bool) {
+	snip := new(snippets.Snippet)
+	if err := s.db.GetSnippet(r.Context(), id, snip); err != nil {
+		if err == datastore.ErrNoSuchEntity {
+			return nil, true
+		}
+		s.log.Errorf("loading Snippet %s: %v", id, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return nil, false
+	}
+	return snip, true
+}
@@ -0,0 +1,116 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/playground/internal/snippets"
+)
+
+func TestLineage(t *testing.T) {
+	s, err := newServer(testingOptions(t))
+	if err != nil {
+		t.Fatalf("newServer(testingOptions(t)): %v", err)
+	}
+	share := func(body, parent string, wantCode int) string {
+		t.Helper()
+		url := "/share"
+		if parent != "" {
+			url += "?parent=" + parent
+		}
+		w := httptest.NewRecorder()
+		s.handleShare(w, httptest.NewRequest("POST", url, strings.NewReader(body)))
+		if w.Code != wantCode {
+			t.Fatalf("POST %s: status %d; want %d", url, w.Code, wantCode)
+		}
+		return w.Body.String()
+	}
+	get := func(id string, wantCode int) *lineage {
+		t.Helper()
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, httptest.NewRequest("GET", "/lineage/"+id, nil))
+		if w.Code != wantCode {
+			t.Fatalf("GET /lineage/%s: status %d; want %d", id, w.Code, wantCode)
+		}
+		if wantCode != http.StatusOK {
+			return nil
+		}
+		l := new(lineage)
+		if err := json.Unmarshal(w.Body.Bytes(), l); err != nil {
+			t.Fatal(err)
+		}
+		return l
+	}
+	ids := func(metas []*snippetMeta) []string {
+		var ids []string
+		for _, m := range metas {
+			id := m.ID
+			if m.Removed {
+				id += " (removed)"
+			}
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	root := share("root", "", http.StatusOK)
+	fork1 := share("fork1", root, http.StatusOK)
+	time.Sleep(time.Millisecond) // to order the forks by Created
+	fork2 := share("fork2", root, http.StatusOK)
+	grandchild := share("grandchild", fork1, http.StatusOK)
+	share("x", "not.valid!", http.StatusBadRequest)
+	share("x", "nonexistent", http.StatusBadRequest)
+
+	// Sharing a snippet again unchanged doesn't make it its own fork,
+	// and sharing it as a fork of another doesn't change its parent.
+	share("root", root, http.StatusOK)
+	share("fork1", fork2, http.StatusOK)
+
+	l := get(grandchild, http.StatusOK)
+	if got, want := ids(l.Ancestors), []string{fork1, root}; !slices.Equal(got, want) {
+		t.Errorf("ancestors of grandchild = %q; want %q", got, want)
+	}
+	if l.Snippet.Parent != fork1 || len(l.Forks) != 0 {
+		t.Errorf("lineage of grandchild = %+v; want parent %s and no forks", l, fork1)
+	}
+	l = get(root, http.StatusOK)
+	if got, want := ids(l.Forks), []string{fork1, fork2}; !slices.Equal(got, want) {
+		t.Errorf("forks of root = %q; want %q", got, want)
+	}
+	if len(l.Ancestors) != 0 {
+		t.Errorf("ancestors of root = %q; want none", ids(l.Ancestors))
+	}
+	get("nonexistent", http.StatusNotFound)
+
+	w := httptest.NewRecorder()
+	s.handleEdit(w, httptest.NewRequest("GET", "http://localhost/p/"+grandchild, nil))
+	if want := `<a id="forkedFrom" href="/p/` + fork1 + `">forked from ` + fork1 + `</a>`; !strings.Contains(w.Body.String(), want) {
+		t.Errorf("edit page of grandchild doesn't contain %s", want)
+	}
+
+	// Removed ancestors are listed by ID, and removed forks not at all.
+	ctx := context.Background()
+	for _, id := range []string{fork1, fork2} {
+		if err := s.db.RemoveSnippet(ctx, id, &snippets.Removal{ID: id, Action: snippets.Delete, Time: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l = get(grandchild, http.StatusOK)
+	if got, want := ids(l.Ancestors), []string{fork1 + " (removed)", root}; !slices.Equal(got, want) {
+		t.Errorf("ancestors of grandchild after removal = %q; want %q", got, want)
+	}
+	if l = get(root, http.StatusOK); len(l.Forks) != 0 {
+		t.Errorf("forks of root after removal = %q; want none", ids(l.Forks))
+	}
+	get(fork1, http.StatusGone)
+}
@@ -14,27 +14,74 @@ import (
 	"cloud.google.com/go/compute/metadata"
 	"cloud.google.com/go/datastore"
 	"golang.org/x/playground/internal/metrics"
+	"golang.org/x/playground/internal/snippets"
+	"golang.org/x/playground/sandbox/sandboxtypes"
 )
 
 var log = newStdLogger()
 
 var (
-	runtests   = flag.Bool("runtests", false, "Run integration tests instead of Playground server.")
-	backendURL = flag.String("backend-url", "", "URL for sandbox backend that runs Go binaries.")
+	runtests       = flag.Bool("runtests", false, "Run integration tests instead of Playground server.")
+	backendURL     = flag.String("backend-url", "", "URL for sandbox backend that runs Go binaries.")
+	backendKeyFile = flag.String("backend-auth-key-file", "", "File containing the key shared with the sandbox backend to sign run requests. If empty, requests are not signed.")
+	policyFile     = flag.String("policy", "", "JSON file of resource limits to use instead of the defaults. Limits set by flags take precedence.")
+	serveBuilds    = flag.Bool("serve-builds", false, "Run as a build service for frontends instead of as a Playground server.")
+	buildURL       = flag.String("build-url", "", "URL of the build service that builds programs. If empty, programs are built locally.")
+	buildKeyFile   = flag.String("build-auth-key-file", "", "File containing the key shared between frontends and the build service to sign build requests. Required with -serve-builds.")
+	snippetDir     = flag.String("snippet-dir", "", "Directory in which to store shared snippets, for self-hosting. If empty, snippets are stored in Cloud Datastore, or in memory if there is no GCP project.")
+	adminKeysFile  = flag.String("admin-keys-file", "", "File of admin names and keys, one pair per line, for the snippet admin API. If empty, the admin API is disabled.")
+	restrictEgress = flag.Bool("restrict-egress", true, "With -serve-builds, only allow the go command to reach the module proxies in PLAY_GOPROXY.")
 )
 
+// policy holds the resource limits enforced by the frontend.
+var policy = sandboxtypes.DefaultPolicy()
+
 func main() {
+	policy.RegisterFlags(flag.CommandLine)
 	flag.Parse()
+	if *policyFile != "" {
+		if err := policy.LoadFile(*policyFile, flag.CommandLine); err != nil {
+			log.Fatalf("Error loading policy: %v", err)
+		}
+	}
+	if err := policy.Validate(); err != nil {
+		log.Fatalf("Invalid policy: %v", err)
+	}
+	if *backendKeyFile != "" {
+		key, err := sandboxtypes.LoadAuthKey(*backendKeyFile)
+		if err != nil {
+			log.Fatalf("Error loading backend auth key: %v", err)
+		}
+		backendAuthKey = key
+	}
+	if *buildKeyFile != "" {
+		key, err := sandboxtypes.LoadAuthKey(*buildKeyFile)
+		if err != nil {
+			log.Fatalf("Error loading build auth key: %v", err)
+		}
+		buildAuthKey = key
+	}
+	if *serveBuilds {
+		serveBuildService()
+		return
+	}
 	s, err := newServer(func(s *server) error {
 		pid := projectID()
-		if pid == "" {
-			s.db = &inMemStore{}
+		if *snippetDir != "" {
+			db, err := snippets.NewFileStore(*snippetDir)
+			if err != nil {
+				return fmt.Errorf("could not open snippet directory: %v", err)
+			}
+			s.db = db
+		} else if pid == "" {
+			log.Printf("Storing snippets in memory: they will be lost on restart. Use -snippet-dir to keep them.")
+			s.db = &snippets.MemStore{}
 		} else {
 			c, err := datastore.NewClient(context.Background(), pid)
 			if err != nil {
 				return fmt.Errorf("could not create cloud datastore client: %v", err)
 			}
-			s.db = cloudDatastore{client: c}
+			s.db = snippets.Datastore{Client: c}
 		}
 		if caddr := os.Getenv("MEMCACHED_ADDR"); caddr != "" {
 			s.cache = newGobCache(caddr)
@@ -47,6 +94,16 @@ func main() {
 		if gotip := os.Getenv("GOTIP"); gotip == "true" {
 			s.gotip = true
 		}
+		if goprev := os.Getenv("GOPREV"); goprev == "true" {
+			s.goprev = true
+		}
+		if *adminKeysFile != "" {
+			keys, err := loadAdminKeys(*adminKeysFile)
+			if err != nil {
+				return fmt.Errorf("loading admin keys: %v", err)
+			}
+			s.adminKeys = keys
+		}
 		execpath, _ := os.Executable()
 		if execpath != "" {
 			if fi, _ := os.Stat(execpath); fi != nil {
@@ -86,6 +143,28 @@ func main() {
 	log.Fatalf("Error listening on :%v: %v", port, http.ListenAndServe(":"+port, s))
 }
 
+// serveBuildService runs the playground as a build service.
+func serveBuildService() {
+	if buildAuthKey == nil {
+		log.Fatalf("-serve-builds requires -build-auth-key-file")
+	}
+	buildVerifier = sandboxtypes.NewBuildVerifier(buildAuthKey)
+	pinModuleEnv = true
+	if *restrictEgress {
+		u, err := startEgressProxy()
+		if err != nil {
+			log.Fatalf("Error starting egress proxy: %v", err)
+		}
+		egressProxyURL = u
+	}
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	log.Printf("Serving builds on :%v ...", port)
+	log.Fatalf("Error listening on :%v: %v", port, http.ListenAndServe(":"+port, buildServiceHandler()))
+}
+
 func enableMetrics(s *server) error {
 	gr, err := metrics.GAEResource(context.Background())
 	if err != nil {
@@ -0,0 +1,146 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"golang.org/x/playground/internal/snippets"
+)
+
+// Mutable snippets have a stable ID and are served at /m/ID, /m/ID.go
+// and /m/ID.json as shared snippets are at /p/ID, but whoever holds
+// their edit token can replace their body. This lets pages link to an
+// example that can be fixed in place.
+//
+// POST /m/ with a snippet creates a mutable snippet, and returns its
+// metadata with the edit token. POST /m/ID with a snippet, and the
+// token as a bearer token, replaces its body. Each body is shared as
+// an ordinary snippet, a revision, whose Parent is the revision it
+// replaced, so /lineage/ID of a revision lists the earlier ones.
+// Once an admin removes a mutable snippet, it is gone for good.
+
+// mutableMeta is the JSON served by /m/ID.json, and in reply to a
+// POST to /m/.
+type mutableMeta struct {
+	ID       string
+	Token    string `json:",omitempty"` // only when created
+	Revision string // ID of the current revision
+	Created  time.Time
+	Updated  time.Time
+}
+
+// newMutableMeta returns the metadata of m, which has the given id.
+func newMutableMeta(id string, m *snippets.Mutable) *mutableMeta {
+	return &mutableMeta{ID: id, Revision: m.Head, Created: m.Created, Updated: m.Updated}
+}
+
+var (
+	errMutableExists   = errors.New("mutable snippet ID already in use")
+	errMutableConflict = errors.New("mutable snippet was updated concurrently")
+	errMutableRemoved  = errors.New("mutable snippet was removed")
+)
+
+func (s *server) handleMutable(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	switch r.Method {
+	case "OPTIONS":
+		// This is likely a pre-flight CORS request, for an update
+		// that sends its token.
+		w.Header().Set("Access-Control-Allow-Headers", "Authorization")
+	case "GET", "HEAD":
+		s.handleEdit(w, r)
+	case "POST":
+		if id := strings.TrimPrefix(r.URL.Path, "/m/"); id != "" {
+			s.updateMutable(w, r, id)
+		} else {
+			s.createMutable(w, r)
+		}
+	default:
+		http.Error(w, "Requires GET or POST", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *server) createMutable(w http.ResponseWriter, r *http.Request) {
+	rev, ok := s.shareSnippet(w, r, "")
+	if !ok {
+		return
+	}
+	m, id, token := snippets.NewMutable(rev)
+	err := s.db.UpdateMutable(r.Context(), id, func(cur *snippets.Mutable) error {
+		if cur.Head != "" {
+			return errMutableExists
+		}
+		*cur = *m
+		return nil
+	})
+	if err != nil {
+		s.log.Errorf("creating mutable Snippet %s: %v", id, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	meta := newMutableMeta(id, m)
+	meta.Token = token
+	s.writeJSONResponse(w, meta, http.StatusOK)
+}
+
+func (s *server) updateMutable(w http.ResponseWriter, r *http.Request, id string) {
+	var m snippets.Mutable
+	if err := s.db.GetMutable(r.Context(), id, &m); err != nil {
+		if err != datastore.ErrNoSuchEntity {
+			s.log.Errorf("loading mutable Snippet %s: %v", id, err)
+		}
+		http.Error(w, "Snippet not found", http.StatusNotFound)
+		return
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !m.CheckToken(token) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if !m.Removed.IsZero() {
+		http.Error(w, "Snippet removed", http.StatusGone)
+		return
+	}
+
+	rev, ok := s.shareSnippet(w, r, m.Head)
+	if !ok {
+		return
+	}
+	err := s.db.UpdateMutable(r.Context(), id, func(cur *snippets.Mutable) error {
+		if !cur.Removed.IsZero() {
+			return errMutableRemoved
+		}
+		// rev's Parent is m.Head, so it must still be current.
+		if cur.Head != m.Head {
+			return errMutableConflict
+		}
+		if cur.Head != rev {
+			cur.Head = rev
+			cur.Updated = time.Now().UTC()
+		}
+		m = *cur
+		return nil
+	})
+	if err == errMutableConflict {
+		http.Error(w, "Snippet was updated concurrently; try again", http.StatusConflict)
+		return
+	}
+	if err == errMutableRemoved {
+		http.Error(w, "Snippet removed", http.StatusGone)
+		return
+	}
+	if err != nil {
+		s.log.Errorf("updating mutable Snippet %s: %v", id, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	s.writeJSONResponse(w, newMutableMeta(id, &m), http.StatusOK)
+}
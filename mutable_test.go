@@ -0,0 +1,106 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/playground/internal/snippets"
+)
+
+func TestMutable(t *testing.T) {
+	s, err := newServer(testingOptions(t))
+	if err != nil {
+		t.Fatalf("newServer(testingOptions(t)): %v", err)
+	}
+	do := func(method, url, token, body string, wantCode int) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		s.mux.ServeHTTP(w, req)
+		if w.Code != wantCode {
+			t.Fatalf("%s %s: status %d; want %d", method, url, w.Code, wantCode)
+		}
+		return w
+	}
+	meta := func(w *httptest.ResponseRecorder) *mutableMeta {
+		t.Helper()
+		m := new(mutableMeta)
+		if err := json.Unmarshal(w.Body.Bytes(), m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	created := meta(do("POST", "/m/", "", "version 1", http.StatusOK))
+	if !snippets.ValidID(created.ID) || created.Token == "" || created.Created.IsZero() {
+		t.Fatalf("POST /m/ = %+v; want an ID, token and creation time", created)
+	}
+	id, rev1 := created.ID, created.Revision
+	if got := do("GET", "/m/"+id+".go", "", "", http.StatusOK).Body.String(); got != "version 1" {
+		t.Errorf("GET /m/%s.go = %q; want %q", id, got, "version 1")
+	}
+	if got := do("GET", "/p/"+rev1+".go", "", "", http.StatusOK).Body.String(); got != "version 1" {
+		t.Errorf("GET /p/%s.go = %q; want %q", rev1, got, "version 1")
+	}
+
+	do("POST", "/m/"+id, "", "version 2", http.StatusUnauthorized)
+	do("POST", "/m/"+id, "wrong", "version 2", http.StatusUnauthorized)
+	do("POST", "/m/nonexistent", created.Token, "version 2", http.StatusNotFound)
+	do("GET", "/m/nonexistent", "", "", http.StatusNotFound)
+
+	updated := meta(do("POST", "/m/"+id, created.Token, "version 2", http.StatusOK))
+	if updated.ID != id || updated.Token != "" || updated.Revision == rev1 || !updated.Created.Equal(created.Created) {
+		t.Errorf("POST /m/%s = %+v; want a new revision of %+v and no token", id, updated, created)
+	}
+	if got := do("GET", "/m/"+id+".go", "", "", http.StatusOK).Body.String(); got != "version 2" {
+		t.Errorf("GET /m/%s.go after update = %q; want %q", id, got, "version 2")
+	}
+	if got := meta(do("GET", "/m/"+id+".json", "", "", http.StatusOK)); *got != *updated {
+		t.Errorf("GET /m/%s.json = %+v; want %+v", id, got, updated)
+	}
+	// The earlier revision is kept, as the parent of the new one.
+	if got := do("GET", "/p/"+rev1+".go", "", "", http.StatusOK).Body.String(); got != "version 1" {
+		t.Errorf("GET /p/%s.go after update = %q; want %q", rev1, got, "version 1")
+	}
+	var rev2 snippets.Snippet
+	if err := s.db.GetSnippet(context.Background(), updated.Revision, &rev2); err != nil || rev2.Parent != rev1 {
+		t.Errorf("revision %s has Parent %q, %v; want %q", updated.Revision, rev2.Parent, err, rev1)
+	}
+
+	// Updating with the same body changes nothing.
+	if again := meta(do("POST", "/m/"+id, created.Token, "version 2", http.StatusOK)); *again != *updated {
+		t.Errorf("POST /m/%s with the same body = %+v; want %+v", id, again, updated)
+	}
+
+	// A browser may check that it can send the token.
+	if got := do("OPTIONS", "/m/"+id, "", "", http.StatusOK).Header().Get("Access-Control-Allow-Headers"); got != "Authorization" {
+		t.Errorf("OPTIONS /m/%s: Access-Control-Allow-Headers %q; want %q", id, got, "Authorization")
+	}
+
+	// Once an admin removes it, it's neither served nor updated, and
+	// neither is its current revision.
+	key := strings.Repeat("k", minAdminKeyLen)
+	s.adminKeys = map[string][]byte{"alice": []byte(key)}
+	do("POST", "/admin/remove", key, `{"ID":"nonexistent","Action":"block","Reason":"abuse","Mutable":true}`, http.StatusNotFound)
+	var rm snippets.Removal
+	w := do("POST", "/admin/remove", key, `{"ID":"`+id+`","Action":"block","Reason":"abuse","Mutable":true}`, http.StatusOK)
+	if err := json.Unmarshal(w.Body.Bytes(), &rm); err != nil || rm.ID != updated.Revision || rm.Mutable != id {
+		t.Errorf("POST /admin/remove of mutable snippet %s = %+v, %v; want the removal of revision %s", id, rm, err, updated.Revision)
+	}
+	for _, url := range []string{"/m/" + id, "/m/" + id + ".go", "/m/" + id + ".json", "/p/" + updated.Revision} {
+		do("GET", url, "", "", http.StatusGone)
+	}
+	do("POST", "/m/"+id, created.Token, "version 3", http.StatusGone)
+	do("POST", "/m/"+id, "wrong", "version 3", http.StatusUnauthorized)
+}
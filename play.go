@@ -36,6 +36,12 @@ var epoch = time.Unix(1257894000, 0)
 //	4 bytes: big-endian int32, length of the next write
 type Recorder struct {
 	stdout, stderr recorderWriter
+
+	// Truncated reports whether the recorded output was cut off by
+	// the sandbox's output limit. If so, the final playback header of
+	// each stream may be incomplete, and is dropped rather than
+	// treated as an error.
+	Truncated bool
 }
 
 func (r *Recorder) Stdout() io.Writer { return &r.stdout }
@@ -61,18 +67,18 @@ func (w *recorderWriter) Write(b []byte) (n int, err error) {
 
 type Event struct {
 	Message string
-	Kind    string        // "stdout" or "stderr"
+	Kind    string        // "stdout", "stderr", or "system" for notes from the playground
 	Delay   time.Duration // time to wait before printing Message
 }
 
 func (r *Recorder) Events() ([]Event, error) {
 	stdout, stderr := r.stdout.bytes(), r.stderr.bytes()
 
-	evOut, err := decode("stdout", stdout)
+	evOut, err := decode("stdout", stdout, r.Truncated)
 	if err != nil {
 		return nil, err
 	}
-	evErr, err := decode("stderr", stderr)
+	evErr, err := decode("stderr", stderr, r.Truncated)
 	if err != nil {
 		return nil, err
 	}
@@ -104,7 +110,9 @@ type event struct {
 	time time.Time
 }
 
-func decode(kind string, output []byte) ([]event, error) {
+// decode splits output into events. If truncated is set, output may
+// end partway through a playback header, which is then discarded.
+func decode(kind string, output []byte, truncated bool) ([]event, error) {
 	var (
 		magic     = []byte{0, 0, 'P', 'B'}
 		headerLen = 8 + 4
@@ -133,7 +141,13 @@ func decode(kind string, output []byte) ([]event, error) {
 			j := bytes.Index(output[i:], magic)
 			if j < 0 {
 				// No more headers; bail.
-				add(last, output[i:])
+				rest := output[i:]
+				if truncated {
+					rest = trimPartialPrefix(rest, magic)
+				}
+				if len(rest) > 0 {
+					add(last, rest)
+				}
 				break
 			}
 			add(last, output[i:i+j])
@@ -143,6 +157,9 @@ func decode(kind string, output []byte) ([]event, error) {
 
 		// Decode header.
 		if len(output)-i < headerLen {
+			if truncated {
+				break
+			}
 			return nil, errors.New("short header")
 		}
 		header := output[i : i+headerLen]
@@ -163,12 +180,27 @@ func decode(kind string, output []byte) ([]event, error) {
 		// Slurp output.
 		// Truncated output is OK (probably caused by sandbox limits).
 		end := min(i+n, len(output))
+		if truncated && end == i && n > 0 {
+			// The frame's data was cut off entirely.
+			break
+		}
 		add(t, output[i:end])
 		i += n
 	}
 	return events, nil
 }
 
+// trimPartialPrefix removes from the end of b the longest proper
+// prefix of p that b ends with.
+func trimPartialPrefix(b, p []byte) []byte {
+	for n := min(len(p)-1, len(b)); n > 0; n-- {
+		if bytes.HasSuffix(b, p[:n]) {
+			return b[:len(b)-n]
+		}
+	}
+	return b
+}
+
 // Sorted merge of two slices of events into one slice.
 func sortedMerge(a, b []event) []event {
 	if len(a) == 0 {
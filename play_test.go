@@ -54,3 +54,37 @@ func pbWrite(offset time.Duration, s string) []byte {
 	binary.BigEndian.PutUint32(out[12:], uint32(len(s)))
 	return append(out, s...)
 }
+
+func TestDecodeTruncated(t *testing.T) {
+	full := append(pbWrite(0, "one"), pbWrite(time.Second, "two")...)
+	for _, tt := range []struct {
+		name string
+		cut  int // bytes to drop from the end of full
+		want []Event
+	}{
+		{"complete", 0, []Event{{"one", "stdout", 0}, {"two", "stdout", time.Second}}},
+		{"short data", 2, []Event{{"one", "stdout", 0}, {"t", "stdout", time.Second}}},
+		{"no data", 3, []Event{{"one", "stdout", 0}}},
+		{"short header", 3 + 5, []Event{{"one", "stdout", 0}}},
+		{"magic only", 3 + 12, []Event{{"one", "stdout", 0}}},
+		{"partial magic", 3 + 12 + 1, []Event{{"one", "stdout", 0}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Recorder{Truncated: true}
+			r.Stdout().Write(full[:len(full)-tt.cut])
+			got, err := r.Events()
+			if err != nil {
+				t.Fatalf("Events: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got: \n%q,\nwant \n%q", got, tt.want)
+			}
+		})
+	}
+
+	r := new(Recorder)
+	r.Stdout().Write(full[:len(full)-3-5])
+	if _, err := r.Events(); err == nil {
+		t.Errorf("Events of short header without Truncated: got nil error, want error")
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/playground/sandbox/sandboxtypes"
+)
+
+// A program can be run more than once, with request.Runs, to check
+// whether its output is deterministic. Faked time makes most programs
+// produce the same output every time, so output that varies between
+// runs usually points at map iteration order, select's random choice,
+// or a data race.
+
+// consistency reports whether a program behaved the same way each
+// time it was run.
+type consistency struct {
+	Runs      int  // how many times the program ran
+	Identical bool // whether every run matched the first
+	// Divergence, if non-nil, describes the first run that didn't
+	// match the first run. No more runs are made after it.
+	Divergence *divergence `json:",omitempty"`
+	// OutOfTime is set if the runs stopped before all that were
+	// asked for were made, as they took too long in total.
+	OutOfTime bool `json:",omitempty"`
+	// Busy is set if the runs stopped before all that were asked
+	// for were made, as the sandbox was too busy to make more.
+	Busy bool `json:",omitempty"`
+}
+
+// A divergence describes how a run's output differed from the
+// first run's.
+type divergence struct {
+	Run    int    // the run that differed, counting from 1
+	Stream string // "stdout", "stderr" or "status"
+	// Diff shows the first lines that differ, prefixed with "-"
+	// for the first run and "+" for Run, after up to
+	// divergenceContext lines that matched, prefixed with " ".
+	Diff string
+}
+
+// runAgainBudget is how many times policy.MaxRunTime the runs after
+// the first may take in total, however many are asked for.
+const runAgainBudget = 2
+
+// divergenceContext and divergenceLines are how many matching lines
+// before the first difference, and how many lines from each run
+// starting at it, a divergence shows.
+const (
+	divergenceContext = 3
+	divergenceLines   = 5
+)
+
+// runAgain runs the binary described by br runs-1 more times, in
+// fresh containers, and compares the output of each run with first,
+// the result of the first run. It stops at the first run that differs,
+// or, setting OutOfTime, once ctx is done, or, setting Busy, once the
+// sandbox is too busy to run the program again.
+func runAgain(ctx context.Context, br *buildResult, first sandboxtypes.Response, runs int) (*consistency, error) {
+	c := &consistency{Runs: 1, Identical: true}
+	for run := 2; run <= runs; run++ {
+		res, err := sandboxRun(ctx, br.exePath, br.testParam, br.dataFiles)
+		if ctx.Err() != nil {
+			// The run was cut short, so its result says nothing
+			// about the program.
+			c.OutOfTime = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if res.Error == runBusyError {
+			c.Busy = true
+			break
+		}
+		c.Runs = run
+		if d := compareRuns(first, res); d != nil {
+			d.Run = run
+			c.Identical = false
+			c.Divergence = d
+			break
+		}
+	}
+	return c, nil
+}
+
+// compareRuns returns how b's output differs from a's, or nil if
+// they're the same. The Run of the result is not set.
+func compareRuns(a, b sandboxtypes.Response) *divergence {
+	if sa, sb := runStatus(a), runStatus(b); sa != sb {
+		return &divergence{Stream: "status", Diff: "-" + sa + "\n+" + sb + "\n"}
+	}
+	if oa, ob := outputText("stdout", a.Stdout, a.Truncated), outputText("stdout", b.Stdout, b.Truncated); !bytes.Equal(oa, ob) {
+		return &divergence{Stream: "stdout", Diff: lineDiff(oa, ob)}
+	}
+	if oa, ob := outputText("stderr", a.Stderr, a.Truncated), outputText("stderr", b.Stderr, b.Truncated); !bytes.Equal(oa, ob) {
+		return &divergence{Stream: "stderr", Diff: lineDiff(oa, ob)}
+	}
+	return nil
+}
+
+// outputText returns the text of output, from the stream kind,
+// without any playback headers, so that runs are compared by what
+// they printed rather than when.
+func outputText(kind string, output []byte, truncated bool) []byte {
+	events, err := decode(kind, output, truncated)
+	if err != nil {
+		return output
+	}
+	var text []byte
+	for _, e := range events {
+		text = append(text, e.msg...)
+	}
+	return text
+}
+
+// runStatus describes how a run ended, for comparing runs.
+func runStatus(res sandboxtypes.Response) string {
+	if res.Error != "" {
+		return res.Error
+	}
+	return fmt.Sprintf("exit status %d", res.ExitCode)
+}
+
+// lineDiff returns a Diff, as in divergence, of a and b, which differ.
+func lineDiff(a, b []byte) string {
+	al := strings.SplitAfter(string(a), "\n")
+	bl := strings.SplitAfter(string(b), "\n")
+	i := 0
+	for i < len(al) && i < len(bl) && al[i] == bl[i] {
+		i++
+	}
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "@@ line %d @@\n", i+1)
+	for _, l := range al[max(i-divergenceContext, 0):i] {
+		writeDiffLine(&buf, " ", l)
+	}
+	for _, l := range al[i:min(i+divergenceLines, len(al))] {
+		writeDiffLine(&buf, "-", l)
+	}
+	for _, l := range bl[i:min(i+divergenceLines, len(bl))] {
+		writeDiffLine(&buf, "+", l)
+	}
+	return buf.String()
+}
+
+func writeDiffLine(buf *strings.Builder, prefix, line string) {
+	if line == "" {
+		// The empty string after a final newline.
+		return
+	}
+	buf.WriteString(prefix)
+	buf.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		buf.WriteString("\n\\ No newline at end of output\n")
+	}
+}
+
+// consistencyEvent returns the "system" Event that tells the user
+// what c found.
+func consistencyEvent(c *consistency) Event {
+	msg := fmt.Sprintf("\n[all %d runs produced the same output]\n", c.Runs)
+	if c.OutOfTime {
+		msg = fmt.Sprintf("\n[all %d runs produced the same output; there was no time for more]\n", c.Runs)
+	}
+	if c.Busy {
+		msg = fmt.Sprintf("\n[all %d runs produced the same output; the sandbox was too busy for more]\n", c.Runs)
+		if c.Runs == 1 {
+			msg = "\n[the sandbox was too busy to run the program again]\n"
+		}
+	}
+	if d := c.Divergence; d != nil {
+		msg = fmt.Sprintf("\n[run %d's %s differed from run 1's:\n%s]\n", d.Run, d.Stream, d.Diff)
+	}
+	return Event{Message: msg, Kind: "system"}
+}
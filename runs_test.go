@@ -0,0 +1,162 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/playground/sandbox/sandboxtypes"
+)
+
+func TestCompareRuns(t *testing.T) {
+	ok := sandboxtypes.Response{Stdout: []byte("a\nb\nc\nd\ne\n")}
+	for _, tc := range []struct {
+		desc string
+		b    sandboxtypes.Response
+		want *divergence
+	}{
+		{"same", sandboxtypes.Response{Stdout: []byte("a\nb\nc\nd\ne\n")}, nil},
+		{"same text, different timing",
+			sandboxtypes.Response{Stdout: []byte("\x00\x00PB\x11\x74\xef\xed\xab\x18\x60\x00\x00\x00\x00\x0aa\nb\nc\nd\ne\n")}, nil},
+		{"exit status", sandboxtypes.Response{Stdout: ok.Stdout, ExitCode: 2},
+			&divergence{Stream: "status", Diff: "-exit status 0\n+exit status 2\n"}},
+		{"timeout", sandboxtypes.Response{Error: runTimeoutError},
+			&divergence{Stream: "status", Diff: "-exit status 0\n+" + runTimeoutError + "\n"}},
+		{"stdout", sandboxtypes.Response{Stdout: []byte("a\nb\nc\nd\nE\n")},
+			&divergence{Stream: "stdout", Diff: "@@ line 5 @@\n b\n c\n d\n-e\n+E\n"}},
+		{"missing newline", sandboxtypes.Response{Stdout: []byte("a\nb\nc\nd\ne")},
+			&divergence{Stream: "stdout", Diff: "@@ line 5 @@\n b\n c\n d\n-e\n+e\n\\ No newline at end of output\n"}},
+		{"stderr", sandboxtypes.Response{Stdout: ok.Stdout, Stderr: []byte("race\n")},
+			&divergence{Stream: "stderr", Diff: "@@ line 1 @@\n+race\n"}},
+	} {
+		got := compareRuns(ok, tc.b)
+		if diff := cmp.Diff(tc.want, got); diff != "" {
+			t.Errorf("%s: compareRuns mismatch (-want +got):\n%s", tc.desc, diff)
+		}
+	}
+}
+
+func TestRunAgain(t *testing.T) {
+	var runs atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res := &sandboxtypes.Response{Stdout: []byte("same\n")}
+		if runs.Add(1) == 3 {
+			res.Stdout = []byte("different\n")
+		}
+		b, _ := res.MarshalFrames()
+		w.Header().Set("Content-Type", sandboxtypes.FramesContentType)
+		w.Write(b)
+	}))
+	defer server.Close()
+	t.Setenv("SANDBOX_BACKEND_URL", server.URL)
+
+	br := &buildResult{exePath: filepath.Join(t.TempDir(), "a.out")}
+	if err := os.WriteFile(br.exePath, []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	first := sandboxtypes.Response{Stdout: []byte("same\n")}
+
+	got, err := runAgain(t.Context(), br, first, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&consistency{Runs: 2, Identical: true}); !cmp.Equal(got, want) {
+		t.Errorf("runAgain(2) = %+v; want %+v", got, want)
+	}
+
+	// The backend's third run differs, which is the third run here.
+	got, err = runAgain(t.Context(), br, first, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &consistency{Runs: 3, Divergence: &divergence{Run: 3, Stream: "stdout", Diff: "@@ line 1 @@\n-same\n+different\n"}}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("runAgain(5) mismatch (-want +got):\n%s", diff)
+	}
+	if n := runs.Load(); n != 3 {
+		t.Errorf("backend ran the program %d times; want 3, stopping at the divergence", n)
+	}
+}
+
+func TestRunAgainOutOfTime(t *testing.T) {
+	var runs atomic.Int32
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if runs.Add(1) > 1 {
+			// Take longer than the runs' time budget.
+			select {
+			case <-r.Context().Done():
+			case <-unblock:
+			}
+			return
+		}
+		b, _ := (&sandboxtypes.Response{Stdout: []byte("same\n")}).MarshalFrames()
+		w.Header().Set("Content-Type", sandboxtypes.FramesContentType)
+		w.Write(b)
+	}))
+	defer server.Close()
+	defer close(unblock)
+	t.Setenv("SANDBOX_BACKEND_URL", server.URL)
+	sandboxBackendClient() // which may take a while the first time
+
+	br := &buildResult{exePath: filepath.Join(t.TempDir(), "a.out")}
+	if err := os.WriteFile(br.exePath, []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	first := sandboxtypes.Response{Stdout: []byte("same\n")}
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	got, err := runAgain(ctx, br, first, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The run that was cut short isn't counted, or taken to differ.
+	if want := (&consistency{Runs: 2, Identical: true, OutOfTime: true}); !cmp.Equal(got, want) {
+		t.Errorf("runAgain out of time = %+v; want %+v", got, want)
+	}
+}
+
+func TestRunAgainBusy(t *testing.T) {
+	var runs atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if runs.Add(1) > 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		b, _ := (&sandboxtypes.Response{Stdout: []byte("same\n")}).MarshalFrames()
+		w.Header().Set("Content-Type", sandboxtypes.FramesContentType)
+		w.Write(b)
+	}))
+	defer server.Close()
+	t.Setenv("SANDBOX_BACKEND_URL", server.URL)
+
+	br := &buildResult{exePath: filepath.Join(t.TempDir(), "a.out")}
+	if err := os.WriteFile(br.exePath, []byte("binary"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	first := sandboxtypes.Response{Stdout: []byte("same\n")}
+
+	// The runs made before the sandbox got busy are still reported.
+	got, err := runAgain(t.Context(), br, first, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&consistency{Runs: 2, Identical: true, Busy: true}); !cmp.Equal(got, want) {
+		t.Errorf("runAgain when busy = %+v; want %+v", got, want)
+	}
+	if msg := consistencyEvent(got).Message; !strings.Contains(msg, "all 2 runs") || !strings.Contains(msg, "too busy") {
+		t.Errorf("consistencyEvent(%+v) = %q; want it to say 2 runs were made before the sandbox was too busy", got, msg)
+	}
+}
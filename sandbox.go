@@ -23,6 +23,7 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -42,10 +43,6 @@ import (
 )
 
 const (
-	// Time for 'go build' to download 3rd-party modules and compile.
-	maxBuildTime = 10 * time.Second
-	maxRunTime   = 5 * time.Second
-
 	// progName is the implicit program name written to the temp
 	// dir and used in compiler and vet errors.
 	progName     = "prog.go"
@@ -55,6 +52,7 @@ const (
 const (
 	goBuildTimeoutError = "timeout running go build"
 	runTimeoutError     = "timeout running program"
+	runBusyError        = "too many programs are running from your address; please try again"
 )
 
 // internalErrors are strings found in responses that will not be cached
@@ -67,15 +65,31 @@ var internalErrors = []string{
 type request struct {
 	Body    string
 	WithVet bool // whether client supports vet response in a /compile request (Issue 31970)
+	// Runs, if more than 1, is how many times a /compile request runs
+	// the program, each time in a new container, to check that its
+	// output doesn't vary. It may be at most policy.MaxRuns.
+	Runs int `json:",omitempty"`
 }
 
 type response struct {
-	Errors      string
-	Events      []Event
+	Errors string
+	Events []Event
+	// Crash, if non-nil, is the runtime panic or fatal error found
+	// in the stderr Events, with file names mapped to the user's files.
+	Crash       *Crash `json:",omitempty"`
 	Status      int
 	IsTest      bool
 	TestsFailed int
 
+	// Truncated reports whether the program's output was cut off at
+	// the sandbox's output limit. A final "system" Event says so.
+	Truncated bool `json:",omitempty"`
+
+	// OutOfMemory reports whether the program was stopped because it
+	// exceeded the sandbox's memory limit. A final "system" Event says
+	// so.
+	OutOfMemory bool `json:",omitempty"`
+
 	// VetErrors, if non-empty, contains any vet errors. It is
 	// only populated if request.WithVet was true.
 	VetErrors string `json:",omitempty"`
@@ -83,6 +97,21 @@ type response struct {
 	// populated if request.WithVet was true. Only one of
 	// VetErrors or VetOK can be non-zero.
 	VetOK bool `json:",omitempty"`
+
+	// Usage, if non-nil, reports the resources the program used
+	// while running, and the limits it ran under.
+	Usage *sandboxtypes.Usage `json:",omitempty"`
+
+	// Consistency, if non-nil, reports whether the program's output
+	// was the same each time it ran, when request.Runs asked for it
+	// to be run more than once. A final "system" Event says so.
+	// The other fields describe the first run.
+	Consistency *consistency `json:",omitempty"`
+
+	// Artifacts are the files the program wrote to its output
+	// directory, sandboxtypes.ArtifactDir, for the client to offer
+	// as downloads.
+	Artifacts []sandboxtypes.Artifact `json:",omitempty"`
 }
 
 // commandHandler returns an http.HandlerFunc.
@@ -90,8 +119,10 @@ type response struct {
 // from the "body" form parameter or from the HTTP request body.
 // If there is no cached *response for the combination of cachePrefix and request.Body,
 // handler calls cmdFunc and in case of a nil error, stores the value of *response in the cache.
+// Unless allowRuns is set, requests that ask for more than one run are rejected;
+// those that are allowed aren't cached.
 // The handler returned supports Cross-Origin Resource Sharing (CORS) from any domain.
-func (s *server) commandHandler(cachePrefix string, cmdFunc func(context.Context, *request) (*response, error)) http.HandlerFunc {
+func (s *server) commandHandler(cachePrefix string, allowRuns bool, cmdFunc func(context.Context, *request) (*response, error)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		cachePrefix := cachePrefix // so we can modify it below
 		w.Header().Set("Access-Control-Allow-Origin", "*")
@@ -100,13 +131,21 @@ func (s *server) commandHandler(cachePrefix string, cmdFunc func(context.Context
 			return
 		}
 
+		r.Body = http.MaxBytesReader(w, r.Body, policy.MaxRequestSize)
 		var req request
 		// Until programs that depend on golang.org/x/tools/godoc/static/playground.js
 		// are updated to always send JSON, this check is in place.
 		if b := r.FormValue("body"); b != "" {
 			req.Body = b
 			req.WithVet, _ = strconv.ParseBool(r.FormValue("withVet"))
+			req.Runs, _ = strconv.Atoi(r.FormValue("runs"))
 		} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			// An oversized form body leaves FormValue empty, and
+			// the same error is then reported again here.
+			if isTooLarge(err) {
+				http.Error(w, "Request is too large", http.StatusRequestEntityTooLarge)
+				return
+			}
 			s.log.Errorf("error decoding request: %v", err)
 			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 			return
@@ -115,47 +154,75 @@ func (s *server) commandHandler(cachePrefix string, cmdFunc func(context.Context
 		if req.WithVet {
 			cachePrefix += "_vet" // "prog" -> "prog_vet"
 		}
+		if req.Runs > 1 && !allowRuns {
+			http.Error(w, "Runs is not supported by this endpoint", http.StatusBadRequest)
+			return
+		}
+		if req.Runs > policy.MaxRuns {
+			http.Error(w, fmt.Sprintf("Runs may be at most %d", policy.MaxRuns), http.StatusBadRequest)
+			return
+		}
+		// Checking whether the output varies means running the
+		// program afresh, so neither use nor fill the cache.
+		useCache := req.Runs <= 1
 
 		resp := &response{}
 		key := cacheKey(cachePrefix, req.Body)
-		if err := s.cache.Get(key, resp); err != nil {
+		if useCache {
+			err := s.cache.Get(key, resp)
+			if err == nil {
+				s.writeJSONResponse(w, resp, http.StatusOK)
+				return
+			}
 			if !errors.Is(err, memcache.ErrCacheMiss) {
 				s.log.Errorf("s.cache.Get(%q, &response): %v", key, err)
 			}
-			resp, err = cmdFunc(r.Context(), &req)
-			if err != nil {
-				s.log.Errorf("cmdFunc error: %v", err)
+		}
+
+		ctx := context.WithValue(r.Context(), clientKey{}, clientAddr(r))
+		resp, err := cmdFunc(ctx, &req)
+		if err != nil {
+			s.log.Errorf("cmdFunc error: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if resp.OutOfMemory {
+			// The program ran out of memory, which is a problem with
+			// the program rather than the playground, but whether it
+			// happens can depend on the sandbox, so don't cache it.
+			s.writeJSONResponse(w, resp, http.StatusOK)
+			return
+		}
+		if strings.Contains(resp.Errors, goBuildTimeoutError) || strings.Contains(resp.Errors, runTimeoutError) ||
+			strings.Contains(resp.Errors, runBusyError) {
+			// TODO(golang.org/issue/38576) - This should be an http.StatusBadRequest,
+			// but the UI requires a 200 to parse the response. It's difficult to know
+			// if we've timed out because of an error in the code snippet, or instability
+			// on the playground itself. Either way, we should try to show the user the
+			// partial output of their program.
+			s.writeJSONResponse(w, resp, http.StatusOK)
+			return
+		}
+		for _, e := range internalErrors {
+			if strings.Contains(resp.Errors, e) {
+				s.log.Errorf("cmdFunc compilation error: %q", resp.Errors)
 				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 				return
 			}
-			if strings.Contains(resp.Errors, goBuildTimeoutError) || strings.Contains(resp.Errors, runTimeoutError) {
-				// TODO(golang.org/issue/38576) - This should be an http.StatusBadRequest,
-				// but the UI requires a 200 to parse the response. It's difficult to know
-				// if we've timed out because of an error in the code snippet, or instability
-				// on the playground itself. Either way, we should try to show the user the
-				// partial output of their program.
-				s.writeJSONResponse(w, resp, http.StatusOK)
-				return
+		}
+		for _, el := range resp.Events {
+			if el.Kind != "stderr" {
+				continue
 			}
 			for _, e := range internalErrors {
-				if strings.Contains(resp.Errors, e) {
-					s.log.Errorf("cmdFunc compilation error: %q", resp.Errors)
+				if strings.Contains(el.Message, e) {
+					s.log.Errorf("cmdFunc runtime error: %q", el.Message)
 					http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 					return
 				}
 			}
-			for _, el := range resp.Events {
-				if el.Kind != "stderr" {
-					continue
-				}
-				for _, e := range internalErrors {
-					if strings.Contains(el.Message, e) {
-						s.log.Errorf("cmdFunc runtime error: %q", el.Message)
-						http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-						return
-					}
-				}
-			}
+		}
+		if useCache {
 			if err := s.cache.Set(key, resp); err != nil {
 				s.log.Errorf("cache.Set(%q, resp): %v", key, err)
 			}
@@ -165,6 +232,25 @@ func (s *server) commandHandler(cachePrefix string, cmdFunc func(context.Context
 	}
 }
 
+// clientKey is the context key for the address of the client that
+// sent a request, as returned by clientAddr.
+type clientKey struct{}
+
+// clientAddr returns the IP address of the client that sent r. Behind
+// Google's load balancer, that's the next to last address in
+// X-Forwarded-For; any before it were supplied by the client.
+func clientAddr(r *http.Request) string {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		addrs := strings.Split(xff, ",")
+		return strings.TrimSpace(addrs[max(0, len(addrs)-2)])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func cacheKey(prefix, body string) string {
 	h := sha256.New()
 	io.WriteString(h, body)
@@ -304,7 +390,7 @@ func compileAndRun(ctx context.Context, req *request) (*response, error) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	br, err := sandboxBuild(ctx, tmpDir, []byte(req.Body), req.WithVet)
+	br, err := buildProgram(ctx, tmpDir, []byte(req.Body), req.WithVet)
 	if err != nil {
 		return nil, err
 	}
@@ -312,15 +398,24 @@ func compileAndRun(ctx context.Context, req *request) (*response, error) {
 		return &response{Errors: removeBanner(br.errorMessage)}, nil
 	}
 
-	execRes, err := sandboxRun(ctx, br.exePath, br.testParam)
+	execRes, err := sandboxRun(ctx, br.exePath, br.testParam, br.dataFiles)
 	if err != nil {
 		return nil, err
 	}
 	if execRes.Error != "" {
 		return &response{Errors: execRes.Error}, nil
 	}
+	var cons *consistency
+	if req.Runs > 1 {
+		rctx, cancel := context.WithTimeout(ctx, runAgainBudget*policy.MaxRunTime)
+		cons, err = runAgain(rctx, br, execRes, req.Runs)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	rec := new(Recorder)
+	rec := &Recorder{Truncated: execRes.Truncated}
 	rec.Stdout().Write(execRes.Stdout)
 	rec.Stderr().Write(execRes.Stderr)
 	events, err := rec.Events()
@@ -328,20 +423,54 @@ func compileAndRun(ctx context.Context, req *request) (*response, error) {
 		log.Printf("error decoding events: %v", err)
 		return nil, fmt.Errorf("error decoding events: %v", err)
 	}
+	if execRes.Truncated {
+		events = append(events, Event{
+			Message: fmt.Sprintf("\n[output truncated: program exceeded the output limit; %d bytes dropped]\n", execRes.DroppedBytes),
+			Kind:    "system",
+		})
+	}
+	if execRes.OutOfMemory {
+		limit := policy.MemoryLimit
+		if execRes.Usage != nil {
+			limit = execRes.Usage.MemoryLimit
+		}
+		events = append(events, Event{
+			Message: fmt.Sprintf("\n[program ran out of memory: the limit is %d MiB]\n", limit>>20),
+			Kind:    "system",
+		})
+	}
+	if execRes.ArtifactsTruncated {
+		events = append(events, Event{
+			Message: fmt.Sprintf("\n[some files in %s/ were not returned: the limit is %d files and %d bytes]\n", sandboxtypes.ArtifactDir, policy.MaxArtifacts, policy.MaxArtifactsSize),
+			Kind:    "system",
+		})
+	}
 	var fails int
+	var rename map[string]string
 	if br.testParam != "" {
 		// In case of testing the TestsFailed field contains how many tests have failed.
 		for _, e := range events {
 			fails += strings.Count(e.Message, failedTestPattern)
 		}
+		rename = map[string]string{progTestName: progName}
+	}
+	if cons != nil {
+		// After counting failed tests, as the diff may repeat them.
+		events = append(events, consistencyEvent(cons))
 	}
 	return &response{
 		Events:      events,
+		Crash:       parseCrash(events, br.dir, rename),
 		Status:      execRes.ExitCode,
+		Truncated:   execRes.Truncated,
+		OutOfMemory: execRes.OutOfMemory,
 		IsTest:      br.testParam != "",
 		TestsFailed: fails,
 		VetErrors:   br.vetOut,
 		VetOK:       req.WithVet && br.vetOut == "",
+		Usage:       execRes.Usage,
+		Artifacts:   execRes.Artifacts,
+		Consistency: cons,
 	}, nil
 }
 
@@ -352,8 +481,15 @@ type buildResult struct {
 	goPath string
 	// exePath is the path to the built binary.
 	exePath string
+	// dir is the directory the binary was built in, which appears in
+	// its stack traces. It differs from exePath's directory if the
+	// binary was built by a build service.
+	dir string
 	// testParam is set if tests should be run when running the binary.
 	testParam string
+	// dataFiles are the files that aren't Go source, such as testdata,
+	// keyed by path. They're laid out in the binary's working directory.
+	dataFiles map[string][]byte
 	// errorMessage is an error message string to be returned to the user.
 	errorMessage string
 	// vetOut is the output of go vet, if requested.
@@ -405,7 +541,15 @@ func sandboxBuild(ctx context.Context, tmpDir string, in []byte, vet bool) (br *
 	}
 
 	var exp []string
+	var dataSize int64
 	for f, src := range files.m {
+		if isDataFile(f) {
+			if br.dataFiles == nil {
+				br.dataFiles = make(map[string][]byte)
+			}
+			br.dataFiles[f] = src
+			dataSize += int64(len(src))
+		}
 		// Before multi-file support we required that the
 		// program be in package main, so continue to do that
 		// for now. But permit anything in subdirectories to have other
@@ -430,7 +574,12 @@ func sandboxBuild(ctx context.Context, tmpDir string, in []byte, vet bool) (br *
 		}
 	}
 
+	if dataSize > policy.MaxDataSize {
+		return &buildResult{errorMessage: fmt.Sprintf("data files are too large: %d bytes, limit is %d", dataSize, policy.MaxDataSize)}, nil
+	}
+
 	br.exePath = filepath.Join(tmpDir, "a.out")
+	br.dir = tmpDir
 	goCache := filepath.Join(tmpDir, "gocache")
 
 	// Copy the gocache directory containing .a files for std, so that we can
@@ -466,7 +615,8 @@ func sandboxBuild(ctx context.Context, tmpDir string, in []byte, vet bool) (br *
 		log.Printf("error creating temp directory: %v", err)
 		return nil, fmt.Errorf("error creating temp directory: %v", err)
 	}
-	cmd.Env = append(cmd.Env, "GO111MODULE=on", "GOPROXY="+playgroundGoproxy())
+	cmd.Env = append(cmd.Env, "GO111MODULE=on")
+	cmd.Env = append(cmd.Env, goproxyEnv()...)
 	cmd.Args = append(cmd.Args, buildPkgArg)
 	cmd.Env = append(cmd.Env, "GOPATH="+br.goPath)
 	out := &bytes.Buffer{}
@@ -475,7 +625,7 @@ func sandboxBuild(ctx context.Context, tmpDir string, in []byte, vet bool) (br *
 	if err := cmd.Start(); err != nil {
 		return nil, fmt.Errorf("error starting go build: %v", err)
 	}
-	ctx, cancel := context.WithTimeout(ctx, maxBuildTime)
+	ctx, cancel := context.WithTimeout(ctx, policy.MaxBuildTime)
 	defer cancel()
 	if err := internal.WaitOrStop(ctx, cmd, os.Interrupt, 250*time.Millisecond); err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
@@ -494,8 +644,7 @@ func sandboxBuild(ctx context.Context, tmpDir string, in []byte, vet bool) (br *
 
 		return br, nil
 	}
-	const maxBinarySize = 100 << 20 // copied from sandbox backend; TODO: unify?
-	if fi, err := os.Stat(br.exePath); err != nil || fi.Size() == 0 || fi.Size() > maxBinarySize {
+	if fi, err := os.Stat(br.exePath); err != nil || fi.Size() == 0 || fi.Size() > policy.MaxBinarySize {
 		if err != nil {
 			return nil, fmt.Errorf("failed to stat binary: %v", err)
 		}
@@ -511,8 +660,30 @@ func sandboxBuild(ctx context.Context, tmpDir string, in []byte, vet bool) (br *
 	return br, nil
 }
 
-// sandboxRun runs a Go binary in a sandbox environment.
-func sandboxRun(ctx context.Context, exePath, testParam string) (execRes sandboxtypes.Response, err error) {
+// buildFileExts are the extensions of the files, besides .go files,
+// that the go command reads when it builds a package.
+var buildFileExts = map[string]bool{
+	".c": true, ".h": true, ".cc": true, ".cpp": true, ".cxx": true, ".hh": true, ".hpp": true, ".hxx": true,
+	".m": true, ".f": true, ".F": true, ".for": true, ".f90": true,
+	".s": true, ".S": true, ".sx": true, ".syso": true, ".swig": true, ".swigcxx": true,
+}
+
+// isDataFile reports whether the named file of a fileSet is data for
+// the program to read when it runs, rather than input to the build.
+func isDataFile(name string) bool {
+	switch name {
+	case "go.mod", "go.sum", "go.work", "go.work.sum":
+		return false
+	}
+	if name == "testdata" || strings.HasPrefix(name, "testdata/") || strings.Contains(name, "/testdata/") {
+		return true
+	}
+	return path.Ext(name) != ".go" && !buildFileExts[path.Ext(name)]
+}
+
+// sandboxRun runs a Go binary in a sandbox environment, with dataFiles
+// in its working directory.
+func sandboxRun(ctx context.Context, exePath, testParam string, dataFiles map[string][]byte) (execRes sandboxtypes.Response, err error) {
 	start := time.Now()
 	defer func() {
 		status := "success"
@@ -528,17 +699,35 @@ func sandboxRun(ctx context.Context, exePath, testParam string) (execRes sandbox
 	if err != nil {
 		return execRes, err
 	}
-	ctx, cancel := context.WithTimeout(ctx, maxRunTime)
+	rr := &sandboxtypes.RunRequest{Binary: exeBytes, Files: dataFiles}
+	if testParam != "" {
+		rr.Args = []string{testParam}
+	}
+	body, err := rr.MarshalFrames()
+	if err != nil {
+		return execRes, fmt.Errorf("encoding request: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, policy.MaxRunTime)
 	defer cancel()
-	sreq, err := http.NewRequestWithContext(ctx, "POST", sandboxBackendURL(), bytes.NewReader(exeBytes))
+	sreq, err := http.NewRequestWithContext(ctx, "POST", sandboxBackendURL(), bytes.NewReader(body))
 	if err != nil {
 		return execRes, fmt.Errorf("NewRequestWithContext %q: %w", sandboxBackendURL(), err)
 	}
+	sreq.Header.Set("Content-Type", sandboxtypes.FramesContentType)
+	sreq.Header.Set("Accept", sandboxtypes.FramesContentType)
 	sreq.Header.Add("Idempotency-Key", "1") // lets Transport do retries with a POST
-	if testParam != "" {
-		sreq.Header.Add("X-Argument", testParam)
+	if client, _ := ctx.Value(clientKey{}).(string); client != "" {
+		sreq.Header.Set(sandboxtypes.ClientHeader, client)
+	}
+	if backendAuthKey != nil {
+		// Retries by the Transport resend the same signature, so
+		// the backend rejects them as replays if the first attempt
+		// reached it. That's rare, and fails safe.
+		if err := sandboxtypes.SignRun(sreq, backendAuthKey, body, time.Now()); err != nil {
+			return execRes, fmt.Errorf("signing request: %w", err)
+		}
 	}
-	sreq.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(exeBytes)), nil }
+	sreq.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
 	res, err := sandboxBackendClient().Do(sreq)
 	if err != nil {
 		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
@@ -548,10 +737,24 @@ func sandboxRun(ctx context.Context, exePath, testParam string) (execRes sandbox
 		return execRes, fmt.Errorf("POST %q: %w", sandboxBackendURL(), err)
 	}
 	defer res.Body.Close()
+	if res.StatusCode == http.StatusTooManyRequests {
+		// The backend is already running as many programs for
+		// this client as it allows.
+		execRes.Error = runBusyError
+		return execRes, nil
+	}
 	if res.StatusCode != http.StatusOK {
 		log.Printf("unexpected response from backend: %v", res.Status)
 		return execRes, fmt.Errorf("unexpected response from backend: %v", res.Status)
 	}
+	if sandboxtypes.IsFramesContentType(res.Header.Get("Content-Type")) {
+		fres, err := sandboxtypes.ReadResponseFrames(res.Body)
+		if err != nil {
+			log.Printf("error reading framed response from backend: %v", err)
+			return execRes, errors.New("error parsing response from backend")
+		}
+		return *fres, nil
+	}
 	if err := json.NewDecoder(res.Body).Decode(&execRes); err != nil {
 		log.Printf("JSON decode error from backend: %v", err)
 		return execRes, errors.New("error parsing JSON from backend")
@@ -571,14 +774,14 @@ func playgroundGoproxy() string {
 }
 
 // healthCheck attempts to build a binary from the source in healthProg.
-// It returns any error returned from sandboxBuild, or nil if none is returned.
+// It returns any error returned from buildProgram, or nil if none is returned.
 func (s *server) healthCheck(ctx context.Context) error {
 	tmpDir, err := os.MkdirTemp("", "sandbox")
 	if err != nil {
 		return fmt.Errorf("error creating temp directory: %v", err)
 	}
 	defer os.RemoveAll(tmpDir)
-	br, err := sandboxBuild(ctx, tmpDir, []byte(healthProg), false)
+	br, err := buildProgram(ctx, tmpDir, []byte(healthProg), false)
 	if err != nil {
 		return err
 	}
@@ -605,6 +808,10 @@ func sandboxBackendURL() string {
 	panic(fmt.Sprintf("no SANDBOX_BACKEND_URL environment and no default defined for project %q", id))
 }
 
+// backendAuthKey is the key shared with the sandbox backend that run
+// requests are signed with, or nil if they aren't signed.
+var backendAuthKey []byte
+
 var sandboxBackendOnce struct {
 	sync.Once
 	c *http.Client
@@ -0,0 +1,249 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// States of a Container, as shown by /debug/containers.
+const (
+	containerWarming = "warming" // starting up
+	containerReady   = "ready"   // waiting to be handed a binary
+	containerRunning = "running" // running a binary
+	containerClosing = "closing" // being stopped
+)
+
+// liveContainers tracks the Containers this process has started and
+// not yet closed, by name, for /debug/containers.
+var liveContainers = struct {
+	sync.Mutex
+	m map[string]*Container
+}{m: map[string]*Container{}}
+
+// track starts tracking c, which is warming up.
+func (c *Container) track() {
+	liveContainers.Lock()
+	defer liveContainers.Unlock()
+	c.created = time.Now()
+	c.state = containerWarming
+	liveContainers.m[c.name] = c
+}
+
+// untrack stops tracking c.
+func (c *Container) untrack() {
+	liveContainers.Lock()
+	defer liveContainers.Unlock()
+	delete(liveContainers.m, c.name)
+}
+
+// setState records that c is now in state.
+func (c *Container) setState(state string) {
+	liveContainers.Lock()
+	defer liveContainers.Unlock()
+	c.state = state
+	if state == containerRunning {
+		c.runStart = time.Now()
+	}
+}
+
+// debugKey, if non-nil, is the token that authenticates requests to
+// /debug/containers. Without one, the page is only served in dev mode.
+var debugKey []byte
+
+// containerInfo describes a Container on /debug/containers.
+type containerInfo struct {
+	Name    string        `json:"name"`
+	State   string        `json:"state"`
+	Age     time.Duration `json:"age"`
+	RunTime time.Duration `json:"runTime,omitempty"` // how long the current run has taken, if running
+}
+
+// clientInfo describes a client's runs on /debug/containers.
+type clientInfo struct {
+	Client  string `json:"client"`
+	Running int    `json:"running"`
+	Queued  int    `json:"queued"`
+}
+
+// debugStatus is the JSON served by /debug/containers.
+type debugStatus struct {
+	Containers []containerInfo `json:"containers"`
+	Pool       poolStatus      `json:"pool"`
+	Clients    []clientInfo    `json:"clients"` // clients with runs in flight
+	Wanted     []string        `json:"wanted"`  // names of containers in containerWanted
+	Draining   bool            `json:"draining,omitempty"`
+	Checked    time.Time       `json:"checked"`
+}
+
+func getDebugStatus() *debugStatus {
+	now := time.Now()
+	st := &debugStatus{
+		Pool:     currentPoolStatus(),
+		Clients:  runSched.clientsInFlight(),
+		Draining: draining.Load(),
+		Checked:  now,
+	}
+
+	liveContainers.Lock()
+	for _, c := range liveContainers.m {
+		ci := containerInfo{Name: c.name, State: c.state, Age: now.Sub(c.created).Round(time.Millisecond)}
+		if c.state == containerRunning {
+			ci.RunTime = now.Sub(c.runStart).Round(time.Millisecond)
+		}
+		st.Containers = append(st.Containers, ci)
+	}
+	liveContainers.Unlock()
+	slices.SortFunc(st.Containers, func(a, b containerInfo) int { return cmp.Compare(b.Age, a.Age) }) // oldest first
+
+	wantedMu.Lock()
+	for name := range containerWanted {
+		st.Wanted = append(st.Wanted, name)
+	}
+	wantedMu.Unlock()
+	slices.Sort(st.Wanted)
+	return st
+}
+
+// debugContainersHandler serves /debug/containers: a page, or JSON if
+// requested, of the sandbox's containers, pool and scheduler. POST
+// requests kill a container (action=kill&name=...) or replace all the
+// pool's idle containers (action=recycle).
+func debugContainersHandler(w http.ResponseWriter, r *http.Request) {
+	if !debugAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="sandbox debug"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case "GET":
+	case "POST":
+		if !sameOrigin(r) {
+			http.Error(w, "cross-origin request", http.StatusForbidden)
+			return
+		}
+		if err := debugAction(r.Context(), r.FormValue("action"), r.FormValue("name")); err != "" {
+			http.Error(w, err, http.StatusBadRequest)
+			return
+		}
+		if wantsJSON(r) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+		return
+	default:
+		http.Error(w, "expected a GET or POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	st := getDebugStatus()
+	if wantsJSON(r) {
+		body, err := json.MarshalIndent(st, "", "  ")
+		if err != nil {
+			http.Error(w, "error encoding JSON", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := debugTemplate.Execute(w, st); err != nil {
+		log.Printf("rendering /debug/containers: %v", err)
+	}
+}
+
+// debugAction does a POSTed action, returning a user-visible error
+// if it's not valid.
+func debugAction(ctx context.Context, action, name string) string {
+	switch action {
+	case "kill":
+		liveContainers.Lock()
+		_, ok := liveContainers.m[name]
+		liveContainers.Unlock()
+		if !ok && !isContainerWanted(name) {
+			return "no such container"
+		}
+		// The container's run, if any, fails and its
+		// worker starts another.
+		reapContainer(ctx, name, "killed via /debug/containers")
+	case "recycle":
+		log.Printf("recycling the pool's idle containers via /debug/containers")
+		pool.recycle()
+	default:
+		return "unknown action"
+	}
+	return ""
+}
+
+// debugAuthorized reports whether r carries debugKey, either as a
+// bearer token or as the password of basic authentication, so that
+// the page can be viewed in a browser.
+func debugAuthorized(r *http.Request) bool {
+	if debugKey == nil {
+		return *dev
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		_, token, ok = r.BasicAuth()
+	}
+	return ok && subtle.ConstantTimeCompare([]byte(token), debugKey) == 1
+}
+
+// sameOrigin reports whether r, a POST, didn't come from another
+// site's page, as a browser would send it with basic authentication.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
+
+func wantsJSON(r *http.Request) bool {
+	return r.FormValue("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<title>Sandbox containers</title>
+<style>
+body { font-family: sans-serif; }
+td, th { padding: 0 1em 0 0; text-align: left; }
+form { display: inline; }
+</style>
+<h1>Sandbox containers</h1>
+{{if .Draining}}<p><b>Draining:</b> the sandbox is shutting down.</p>{{end}}
+<p>Runs: {{.Pool.Busy}} of {{.Pool.Capacity}} busy, {{.Pool.Queued}} queued.
+Pool target: {{.Pool.Target}} warm containers.
+<form method="post"><input type="hidden" name="action" value="recycle"><button>Recycle idle containers</button></form>
+<table>
+<tr><th>Name</th><th>State</th><th>Age</th><th>Run time</th><th></th></tr>
+{{range .Containers}}<tr>
+<td>{{.Name}}</td><td>{{.State}}</td><td>{{.Age}}</td><td>{{if .RunTime}}{{.RunTime}}{{end}}</td>
+<td><form method="post"><input type="hidden" name="action" value="kill"><input type="hidden" name="name" value="{{.Name}}"><button>Kill</button></form></td>
+</tr>{{end}}
+</table>
+<h2>Clients</h2>
+<table>
+<tr><th>Client</th><th>Running</th><th>Queued</th></tr>
+{{range .Clients}}<tr><td>{{.Client}}</td><td>{{.Running}}</td><td>{{.Queued}}</td></tr>{{end}}
+</table>
+<h2>Wanted containers</h2>
+<ul>{{range .Wanted}}<li>{{.}}</li>{{end}}</ul>
+<p>Checked {{.Checked.Format "2006-01-02 15:04:05 MST"}}. <a href="?format=json">JSON</a></p>
+`))
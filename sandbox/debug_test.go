@@ -0,0 +1,95 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDebugContainers(t *testing.T) {
+	oldRuntime, oldRunSched, oldKey := sandboxRuntime, runSched, debugKey
+	defer func() { sandboxRuntime, runSched, debugKey = oldRuntime, oldRunSched, oldKey }()
+	rt := &fakeRuntime{}
+	sandboxRuntime = rt
+	runSched = newFairScheduler(2, 2)
+	debugKey = []byte("secret")
+
+	c := &Container{name: "play_run_debug"}
+	c.track()
+	defer c.untrack()
+	c.setState(containerRunning)
+	setContainerWanted(c.name, true)
+	defer setContainerWanted(c.name, false)
+	release, err := runSched.acquire(t.Context(), "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	do := func(method, target, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		debugContainersHandler(w, req)
+		return w
+	}
+
+	if w := do("GET", "/debug/containers?format=json", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("without a token, status = %d; want %d", w.Code, http.StatusUnauthorized)
+	}
+	if w := do("GET", "/debug/containers?format=json", "Bearer wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("with the wrong token, status = %d; want %d", w.Code, http.StatusUnauthorized)
+	}
+
+	w := do("GET", "/debug/containers?format=json", "Bearer secret")
+	var st debugStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatalf("decoding %q: %v", w.Body, err)
+	}
+	i := slices.IndexFunc(st.Containers, func(ci containerInfo) bool { return ci.Name == c.name })
+	if i < 0 || st.Containers[i].State != containerRunning {
+		t.Errorf("containers = %+v; want %s running", st.Containers, c.name)
+	}
+	if !slices.Contains(st.Wanted, c.name) {
+		t.Errorf("wanted = %q; want it to include %s", st.Wanted, c.name)
+	}
+	if want := []clientInfo{{Client: "client", Running: 1}}; !slices.Equal(st.Clients, want) || st.Pool.Busy != 1 {
+		t.Errorf("clients = %+v, pool = %+v; want %+v and 1 busy", st.Clients, st.Pool, want)
+	}
+
+	req := httptest.NewRequest("GET", "/debug/containers", nil)
+	req.SetBasicAuth("", "secret")
+	w = httptest.NewRecorder()
+	debugContainersHandler(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), c.name) {
+		t.Errorf("HTML page = %d %q; want 200 listing %s", w.Code, w.Body, c.name)
+	}
+
+	if w := do("POST", "/debug/containers?format=json&action=kill&name=play_run_other", "Bearer secret"); w.Code != http.StatusBadRequest {
+		t.Errorf("killing an unknown container: status = %d; want %d", w.Code, http.StatusBadRequest)
+	}
+	if w := do("POST", "/debug/containers?format=json&action=kill&name="+c.name, "Bearer secret"); w.Code != http.StatusNoContent {
+		t.Errorf("killing a container: status = %d; want %d", w.Code, http.StatusNoContent)
+	}
+	if !slices.Equal(rt.killed, []string{c.name}) {
+		t.Errorf("killed %q; want %q", rt.killed, c.name)
+	}
+
+	req = httptest.NewRequest("POST", "/debug/containers?action=recycle", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Origin", "https://evil.example")
+	w = httptest.NewRecorder()
+	debugContainersHandler(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("cross-origin POST: status = %d; want %d", w.Code, http.StatusForbidden)
+	}
+}
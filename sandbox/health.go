@@ -0,0 +1,228 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// readiness is the result of a readiness check, served as JSON by
+// /readyz. The sandbox is ready to take requests only if every part
+// of the check passed.
+type readiness struct {
+	Ready    bool        `json:"ready"`
+	Draining bool        `json:"draining,omitempty"` // the sandbox is shutting down
+	Runtime  checkResult `json:"runtime"`            // whether the container runtime (such as Docker) is available
+	Pool     poolStatus  `json:"pool"`
+	Exec     checkResult `json:"exec"` // whether a known binary ran correctly in a container
+	Checked  time.Time   `json:"checked"`
+}
+
+type checkResult struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// poolStatus is the state of the container pool.
+type poolStatus struct {
+	Saturated bool `json:"saturated"` // all run slots are busy, so new requests would queue; doesn't affect readiness
+	Busy      int  `json:"busy"`      // runs in progress
+	Queued    int  `json:"queued"`    // runs waiting for a slot
+	Capacity  int  `json:"capacity"`  // maximum concurrent runs
+	Target    int  `json:"target"`    // containers the pool is keeping warm
+}
+
+var healthStatus struct {
+	sync.Mutex
+	lastCheck time.Time
+	lastVal   *readiness
+}
+
+func getReadinessCached() *readiness {
+	if draining.Load() {
+		// Don't wait out the cache, or check anything else:
+		// a draining sandbox is never ready.
+		return &readiness{Draining: true, Pool: currentPoolStatus(), Checked: time.Now()}
+	}
+	healthStatus.Lock()
+	defer healthStatus.Unlock()
+	const recentEnough = 5 * time.Second
+	if healthStatus.lastCheck.After(time.Now().Add(-recentEnough)) {
+		return healthStatus.lastVal
+	}
+
+	rd := checkReadiness(healthStatus.lastVal)
+	if (healthStatus.lastVal == nil || healthStatus.lastVal.Ready) && !rd.Ready {
+		// On transition from ready to unready, close all
+		// idle HTTP connections so clients with them open
+		// don't reuse them. TODO: remove this if/when we
+		// switch away from direct load balancing between
+		// frontends and this sandbox backend.
+		httpServer.SetKeepAlivesEnabled(false) // side effect of closing all idle ones
+		httpServer.SetKeepAlivesEnabled(true)  // and restore it back to normal
+	}
+	healthStatus.lastVal = rd
+	healthStatus.lastCheck = time.Now()
+	return rd
+}
+
+// checkReadiness does a readiness check, without any caching. It's
+// called via getReadinessCached, with the previous result, if any.
+//
+// A saturated pool is reported, but doesn't make the sandbox unready:
+// it would drop busy sandboxes from rotation just when they're all
+// needed.
+func checkReadiness(prev *readiness) *readiness {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	rd := &readiness{Checked: time.Now()}
+
+	if err := sandboxRuntime.Check(ctx); err != nil {
+		rd.Runtime.Error = err.Error()
+	} else {
+		rd.Runtime.OK = true
+	}
+
+	rd.Pool = currentPoolStatus()
+
+	switch {
+	case !rd.Runtime.OK:
+		rd.Exec.Error = "skipped: runtime unavailable"
+	case rd.Pool.Saturated:
+		// Running the check would wait for a run slot. The runs
+		// using them show that containers work, so keep the
+		// last result.
+		rd.Exec = checkResult{OK: true}
+		if prev != nil {
+			rd.Exec = prev.Exec
+		}
+	default:
+		if err := checkExec(ctx); err != nil {
+			rd.Exec.Error = err.Error()
+		} else {
+			rd.Exec.OK = true
+		}
+	}
+
+	rd.Ready = rd.Runtime.OK && rd.Exec.OK
+	return rd
+}
+
+func currentPoolStatus() poolStatus {
+	ps := poolStatus{
+		Busy:     runSched.busy(),
+		Queued:   runSched.queued(),
+		Capacity: runSched.capacity,
+		Target:   pool.size(),
+	}
+	ps.Saturated = ps.Busy >= ps.Capacity
+	return ps
+}
+
+// healthCheckClient is the runSched client of the health check's runs.
+const healthCheckClient = "sandbox-health-check"
+
+// checkExec runs healthCheckBinary in a container and checks its
+// output. Like a /run request, it takes a run slot from runSched.
+func checkExec(ctx context.Context) error {
+	release, err := runSched.acquire(ctx, healthCheckClient)
+	if err != nil {
+		return fmt.Errorf("waiting for a run slot: %v", err)
+	}
+	defer release()
+	c, err := getContainer(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a sandbox container: %v", err)
+	}
+	res, err := runInContainer(ctx, c, healthCheckBinary(), processMeta{}, func(string, ...any) {})
+	if err != nil {
+		return fmt.Errorf("running health check binary: %v", err)
+	}
+	if res.ExitCode != 0 || string(res.Stdout) != healthCheckOutput {
+		return fmt.Errorf("health check binary exited with %d and wrote %q; want 0 and %q", res.ExitCode, res.Stdout, healthCheckOutput)
+	}
+	return nil
+}
+
+// livenessHandler reports that the server is up. Failing it means
+// the sandbox should be restarted, so it doesn't depend on load or
+// on the container runtime.
+func livenessHandler(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, "OK\n")
+}
+
+// readinessHandler reports whether the sandbox can take requests.
+// Frontends only send requests to ready sandboxes.
+func readinessHandler(w http.ResponseWriter, r *http.Request) {
+	rd := getReadinessCached()
+	body, err := json.MarshalIndent(rd, "", "  ")
+	if err != nil {
+		http.Error(w, "error encoding JSON", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !rd.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(body)
+}
+
+// healthCheckOutput is what healthCheckBinary writes to stdout.
+const healthCheckOutput = "golang-sandbox-health-check-ok\n"
+
+// healthCheckBinary returns a minimal static linux/amd64 executable
+// that writes healthCheckOutput to stdout and exits 0. It's tiny, so
+// running it through a container is a cheap end-to-end check.
+func healthCheckBinary() []byte {
+	const (
+		base    = 0x400000
+		hdrSize = 64 + 56 // ELF header + one program header
+	)
+	code := []byte{
+		0xb8, 0x01, 0x00, 0x00, 0x00, // mov eax, 1 (write)
+		0xbf, 0x01, 0x00, 0x00, 0x00, // mov edi, 1 (stdout)
+		0x48, 0x8d, 0x35, 0x10, 0x00, 0x00, 0x00, // lea rsi, [rip+16] (message)
+		0xba, byte(len(healthCheckOutput)), 0x00, 0x00, 0x00, // mov edx, len(message)
+		0x0f, 0x05, // syscall
+		0xb8, 0x3c, 0x00, 0x00, 0x00, // mov eax, 60 (exit)
+		0x31, 0xff, // xor edi, edi
+		0x0f, 0x05, // syscall
+	}
+	size := uint64(hdrSize + len(code) + len(healthCheckOutput))
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, elf.Header64{
+		Ident:     [elf.EI_NIDENT]byte{0x7f, 'E', 'L', 'F', byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)},
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Entry:     base + hdrSize,
+		Phoff:     64,
+		Ehsize:    64,
+		Phentsize: 56,
+		Phnum:     1,
+	})
+	binary.Write(&buf, binary.LittleEndian, elf.Prog64{
+		Type:   uint32(elf.PT_LOAD),
+		Flags:  uint32(elf.PF_R | elf.PF_X),
+		Vaddr:  base,
+		Paddr:  base,
+		Filesz: size,
+		Memsz:  size,
+		Align:  0x1000,
+	})
+	buf.Write(code)
+	buf.WriteString(healthCheckOutput)
+	return buf.Bytes()
+}
@@ -0,0 +1,86 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckBinary(t *testing.T) {
+	if runtime.GOOS != "linux" || runtime.GOARCH != "amd64" {
+		t.Skipf("health check binary is linux/amd64; running on %s/%s", runtime.GOOS, runtime.GOARCH)
+	}
+	bin := filepath.Join(t.TempDir(), "healthcheck")
+	if err := os.WriteFile(bin, healthCheckBinary(), 0755); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(bin).Output()
+	if err != nil {
+		t.Fatalf("running health check binary: %v", err)
+	}
+	if string(out) != healthCheckOutput {
+		t.Errorf("health check binary wrote %q; want %q", out, healthCheckOutput)
+	}
+}
+
+func TestCheckReadiness(t *testing.T) {
+	oldRuntime, oldRunSched := sandboxRuntime, runSched
+	defer func() { sandboxRuntime, runSched = oldRuntime, oldRunSched }()
+
+	// The runtime is unavailable.
+	sandboxRuntime = &fakeRuntime{checkErr: errors.New("no docker")}
+	runSched = newFairScheduler(2, 2)
+	rd := checkReadiness(nil)
+	if rd.Ready || rd.Runtime.OK || rd.Runtime.Error != "no docker" || rd.Exec.OK {
+		t.Errorf("with runtime unavailable, checkReadiness(nil) = %+v", rd)
+	}
+
+	// The pool is saturated. That's reported, but the sandbox is
+	// still ready, unless its last exec check failed.
+	sandboxRuntime = &fakeRuntime{}
+	for range 2 {
+		if _, err := runSched.acquire(t.Context(), "client"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rd = checkReadiness(nil)
+	want := poolStatus{Saturated: true, Busy: 2, Capacity: 2}
+	if !rd.Ready || !rd.Runtime.OK || rd.Pool != want || !rd.Exec.OK {
+		t.Errorf("with pool saturated, checkReadiness(nil) = %+v; want ready with pool %+v", rd, want)
+	}
+	failed := &readiness{Exec: checkResult{Error: "health check binary exited with 1"}}
+	if rd = checkReadiness(failed); rd.Ready || rd.Exec != failed.Exec {
+		t.Errorf("with pool saturated after a failed check, checkReadiness = %+v; want not ready with exec %+v", rd, failed.Exec)
+	}
+}
+
+func TestCheckExecUsesRunSlot(t *testing.T) {
+	oldRunSched := runSched
+	defer func() { runSched = oldRunSched }()
+	runSched = newFairScheduler(1, 1)
+	release, err := runSched.acquire(t.Context(), "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	// With the only slot taken, the check waits for it until its
+	// context is done, rather than taking a container itself.
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	if err := checkExec(ctx); err == nil || !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("checkExec with no free run slot = %v; want an error once its context is done", err)
+	}
+	if n := runSched.busy(); n != 1 {
+		t.Errorf("after checkExec, %d runs busy; want 1", n)
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package main
+
+import "os"
+
+// maxRSS returns 0: peak memory is only measured on Linux, where the
+// contained process runs.
+func maxRSS(ps *os.ProcessState) int64 { return 0 }
@@ -14,11 +14,23 @@ import (
 var (
 	kContainerCreateSuccess = tag.MustNewKey("go-playground/sandbox/container_create_success")
 	kContainerWaitStatus    = tag.MustNewKey("go-playground/sandbox/container_wait_status")
+	kContainerReapStatus    = tag.MustNewKey("go-playground/sandbox/container_reap_status")
+	kRunOutcome             = tag.MustNewKey("go-playground/sandbox/run_outcome")
+	kRunExitCode            = tag.MustNewKey("go-playground/sandbox/run_exit_code")
 	mContainers             = stats.Int64("go-playground/sandbox/container_count", "number of sandbox containers", stats.UnitDimensionless)
 	mUnwantedContainers     = stats.Int64("go-playground/sandbox/unwanted_container_count", "number of sandbox containers that are unexpectedly running", stats.UnitDimensionless)
+	mReapedContainers       = stats.Int64("go-playground/sandbox/reaped_container_count", "number of unwanted sandbox containers removed", stats.UnitDimensionless)
 	mMaxContainers          = stats.Int64("go-playground/sandbox/max_container_count", "target number of sandbox containers", stats.UnitDimensionless)
 	mContainerCreateLatency = stats.Float64("go-playground/sandbox/container_create_latency", "", stats.UnitMilliseconds)
 	mContainerWaitLatency   = stats.Float64("go-playground/sandbox/container_wait_latency", "latency of waiting for a ready container", stats.UnitMilliseconds)
+	mRunWallTime            = stats.Float64("go-playground/sandbox/run_wall_time", "wall time of running a binary", stats.UnitMilliseconds)
+	mRunCPUTime             = stats.Float64("go-playground/sandbox/run_cpu_time", "user plus system CPU time of running a binary", stats.UnitMilliseconds)
+	mRunMaxRSS              = stats.Int64("go-playground/sandbox/run_max_rss", "peak resident set size of a binary", stats.UnitBytes)
+	mRunOutputBytes         = stats.Int64("go-playground/sandbox/run_output_bytes", "bytes written to stdout and stderr by a binary", stats.UnitBytes)
+	mRuns                   = stats.Int64("go-playground/sandbox/run_count", "number of binaries run", stats.UnitDimensionless)
+	mRunDuration            = stats.Float64("go-playground/sandbox/run_duration", "time to run a binary, including sending it to its container", stats.UnitMilliseconds)
+	mRunBinaryBytes         = stats.Int64("go-playground/sandbox/run_binary_bytes", "size of a binary run", stats.UnitBytes)
+	mClientRejections       = stats.Int64("go-playground/sandbox/client_rejection_count", "number of /run requests rejected because their client had too many in flight", stats.UnitDimensionless)
 
 	containerCount = &view.View{
 		Name:        "go-playground/sandbox/container_count",
@@ -34,6 +46,13 @@ var (
 		Measure:     mUnwantedContainers,
 		Aggregation: view.LastValue(),
 	}
+	reapedContainerCount = &view.View{
+		Name:        "go-playground/sandbox/reaped_container_count",
+		Description: "Number of unwanted containers removed",
+		TagKeys:     []tag.Key{kContainerReapStatus},
+		Measure:     mReapedContainers,
+		Aggregation: view.Sum(),
+	}
 	maxContainerCount = &view.View{
 		Name:        "go-playground/sandbox/max_container_count",
 		Description: "Maximum number of containers to create",
@@ -41,6 +60,13 @@ var (
 		Measure:     mMaxContainers,
 		Aggregation: view.LastValue(),
 	}
+	clientRejectionCount = &view.View{
+		Name:        "go-playground/sandbox/client_rejection_count",
+		Description: "Number of /run requests rejected because their client had too many in flight",
+		TagKeys:     nil,
+		Measure:     mClientRejections,
+		Aggregation: view.Sum(),
+	}
 	containerCreateCount = &view.View{
 		Name:        "go-playground/sandbox/container_create_count",
 		Description: "Number of containers created",
@@ -68,6 +94,65 @@ var (
 		TagKeys:     []tag.Key{kContainerWaitStatus},
 		Aggregation: ochttp.DefaultLatencyDistribution,
 	}
+	runWallTime = &view.View{
+		Name:        "go-playground/sandbox/run_wall_time",
+		Description: "Wall time distribution of running binaries",
+		Measure:     mRunWallTime,
+		Aggregation: ochttp.DefaultLatencyDistribution,
+	}
+	runCPUTime = &view.View{
+		Name:        "go-playground/sandbox/run_cpu_time",
+		Description: "CPU time distribution of running binaries",
+		Measure:     mRunCPUTime,
+		Aggregation: ochttp.DefaultLatencyDistribution,
+	}
+	runMaxRSS = &view.View{
+		Name:        "go-playground/sandbox/run_max_rss",
+		Description: "Peak memory distribution of running binaries",
+		Measure:     mRunMaxRSS,
+		Aggregation: ochttp.DefaultSizeDistribution,
+	}
+	runOutputBytes = &view.View{
+		Name:        "go-playground/sandbox/run_output_bytes",
+		Description: "Size distribution of output written by running binaries",
+		Measure:     mRunOutputBytes,
+		Aggregation: ochttp.DefaultSizeDistribution,
+	}
+	runCount = &view.View{
+		Name:        "go-playground/sandbox/run_count",
+		Description: "Number of runs by outcome",
+		Measure:     mRuns,
+		TagKeys:     []tag.Key{kRunOutcome},
+		Aggregation: view.Count(),
+	}
+	runExitCodeCount = &view.View{
+		Name:        "go-playground/sandbox/run_exit_code_count",
+		Description: "Number of completed runs by exit code",
+		Measure:     mRuns,
+		TagKeys:     []tag.Key{kRunExitCode},
+		Aggregation: view.Count(),
+	}
+	runDuration = &view.View{
+		Name:        "go-playground/sandbox/run_duration",
+		Description: "Latency distribution of runs by outcome",
+		Measure:     mRunDuration,
+		TagKeys:     []tag.Key{kRunOutcome},
+		Aggregation: ochttp.DefaultLatencyDistribution,
+	}
+	runOutputBytesByOutcome = &view.View{
+		Name:        "go-playground/sandbox/run_output_bytes_by_outcome",
+		Description: "Size distribution of output written by running binaries, by outcome",
+		Measure:     mRunOutputBytes,
+		TagKeys:     []tag.Key{kRunOutcome},
+		Aggregation: ochttp.DefaultSizeDistribution,
+	}
+	runBinaryBytes = &view.View{
+		Name:        "go-playground/sandbox/run_binary_bytes",
+		Description: "Size distribution of binaries run, by outcome",
+		Measure:     mRunBinaryBytes,
+		TagKeys:     []tag.Key{kRunOutcome},
+		Aggregation: ochttp.DefaultSizeDistribution,
+	}
 )
 
 // Customizations of ochttp views. Views are updated as follows:
@@ -123,11 +208,22 @@ var (
 var views = []*view.View{
 	containerCount,
 	unwantedContainerCount,
+	reapedContainerCount,
 	maxContainerCount,
+	clientRejectionCount,
 	containerCreateCount,
 	containerCreationLatency,
 	containerWaitCount,
 	containerWaitLatency,
+	runWallTime,
+	runCPUTime,
+	runMaxRSS,
+	runOutputBytes,
+	runCount,
+	runExitCodeCount,
+	runDuration,
+	runOutputBytesByOutcome,
+	runBinaryBytes,
 	ServerRequestCountView,
 	ServerRequestBytesView,
 	ServerResponseBytesView,
@@ -0,0 +1,259 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"golang.org/x/playground/internal"
+)
+
+const (
+	// poolAdjustInterval is how often the pool size is adjusted.
+	poolAdjustInterval = 10 * time.Second
+
+	// slowWait is how long a request can wait for a container
+	// before the pool is considered too small.
+	slowWait = 50 * time.Millisecond
+
+	// refillTime is roughly how long it takes to start a container
+	// to replace one that was handed out. The pool keeps enough
+	// containers warm for the requests expected in that time.
+	refillTime = 2 * time.Second
+)
+
+// pool is the pool of workers that keep containers warm. It's nil
+// until main starts it.
+var pool *workerPool
+
+// startRetryDelay is how long a worker waits to start another
+// container after failing to start one.
+const startRetryDelay = 5 * time.Second
+
+// workerPool runs between lo and hi workers, each of which keeps one
+// container warm, and periodically adjusts the number to the load.
+type workerPool struct {
+	lo, hi    int
+	startTick *time.Ticker // rate-limits container starts
+
+	mu       sync.Mutex
+	target   int           // number of workers wanted
+	workers  int           // number of workers running
+	nextID   int           // ID of the next worker, for logging
+	shrunk   chan struct{} // closed when target shrinks, to wake idle workers
+	recycled chan struct{} // closed by recycle, to replace idle containers
+	load     poolLoad      // load since the last adjustment
+
+	running sync.WaitGroup // workers that haven't exited
+}
+
+// poolLoad is the load on the pool over one adjustment interval.
+type poolLoad struct {
+	requests int           // /run requests received
+	busy     int           // requests being handled when the interval ended
+	queued   int           // requests waiting to be handled then
+	maxWait  time.Duration // longest a request waited for a container
+}
+
+// newWorkerPool returns a pool of between lo and hi workers that
+// start at most one container every startInterval.
+func newWorkerPool(lo, hi int, startInterval time.Duration) *workerPool {
+	return &workerPool{
+		lo:        lo,
+		hi:        hi,
+		startTick: time.NewTicker(startInterval),
+		shrunk:    make(chan struct{}),
+		recycled:  make(chan struct{}),
+	}
+}
+
+// run starts the pool's minimum number of workers and then adjusts
+// the number every poolAdjustInterval until ctx is done.
+func (p *workerPool) run(ctx context.Context) {
+	defer p.startTick.Stop()
+	log.Printf("workerPool: keeping between %d and %d containers warm", p.lo, p.hi)
+	p.setTarget(ctx, p.lo)
+	internal.PeriodicallyDo(ctx, poolAdjustInterval, func(ctx context.Context, _ time.Time) {
+		p.mu.Lock()
+		load := p.load
+		p.load = poolLoad{}
+		cur := p.target
+		p.mu.Unlock()
+
+		load.busy = runSched.busy()
+		load.queued = runSched.queued()
+		if n := nextPoolSize(cur, p.lo, p.hi, load, poolAdjustInterval); n != cur {
+			log.Printf("workerPool: resizing from %d to %d (load %+v)", cur, n, load)
+			p.setTarget(ctx, n)
+		}
+	})
+}
+
+// setTarget sets the number of workers wanted, starting workers to
+// reach it or waking idle ones so the extras exit.
+func (p *workerPool) setTarget(ctx context.Context, n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n < p.target {
+		close(p.shrunk)
+		p.shrunk = make(chan struct{})
+	}
+	p.target = n
+	for ; p.workers < n; p.workers++ {
+		p.running.Add(1)
+		go p.workerLoop(ctx, p.nextID)
+		p.nextID++
+	}
+	stats.Record(ctx, mMaxContainers.M(int64(n)))
+}
+
+// retire reports whether the calling worker should exit because the
+// pool has more workers than it wants. If so, it's no longer counted.
+// Otherwise, it returns the channel that's closed when the pool next
+// shrinks.
+func (p *workerPool) retire() (bool, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.workers > p.target {
+		p.workers--
+		return true, nil
+	}
+	return false, p.shrunk
+}
+
+// size returns the number of containers the pool is keeping warm.
+func (p *workerPool) size() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.target
+}
+
+// wait waits for the pool's workers to exit, which they do, closing
+// their containers, once the context passed to run is done.
+func (p *workerPool) wait() {
+	if p == nil {
+		return
+	}
+	p.running.Wait()
+}
+
+// recycle makes the pool replace the containers it's keeping warm,
+// for instance if they were started with a bad image.
+func (p *workerPool) recycle() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	close(p.recycled)
+	p.recycled = make(chan struct{})
+}
+
+// recycledChan returns the channel that's closed when the pool next
+// recycles its containers.
+func (p *workerPool) recycledChan() <-chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.recycled
+}
+
+// observeWait records that a container was requested and that
+// getting one took d.
+func (p *workerPool) observeWait(d time.Duration) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.load.requests++
+	p.load.maxWait = max(p.load.maxWait, d)
+}
+
+func (p *workerPool) workerLoop(ctx context.Context, id int) {
+	log.Printf("workerLoop %d: started", id)
+	defer p.running.Done()
+	defer log.Printf("workerLoop %d: exiting", id)
+	for {
+		if retired, _ := p.retire(); retired {
+			return
+		}
+		select {
+		case <-p.startTick.C:
+		case <-ctx.Done():
+			return
+		}
+		recycled := p.recycledChan()
+		c, err := startContainer(ctx)
+		if err != nil {
+			log.Printf("workerLoop %d: error starting container: %v", id, err)
+			select {
+			case <-time.After(startRetryDelay):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		handed, exit := p.handOff(ctx, c, recycled)
+		if !handed {
+			c.Close()
+		}
+		if exit {
+			return
+		}
+	}
+}
+
+// handOff waits to give c to a request, and reports whether it did.
+// It gives up if c exits, if recycled is closed, or if the worker
+// should exit, which it also reports.
+func (p *workerPool) handOff(ctx context.Context, c *Container, recycled <-chan struct{}) (handed, exit bool) {
+	for {
+		retired, shrunk := p.retire()
+		if retired {
+			return false, true
+		}
+		select {
+		case readyContainer <- c:
+			return true, false
+		case <-shrunk:
+		case <-c.exited:
+			log.Printf("container %q exited before it was used", c.name)
+			return false, false
+		case <-recycled:
+			return false, false
+		case <-ctx.Done():
+			return false, true
+		}
+	}
+}
+
+// nextPoolSize returns the number of containers to keep warm, given
+// the current number, the bounds lo and hi, and the load over the
+// last interval. It grows the pool quickly when requests had to wait
+// and shrinks it gradually when it's bigger than the load needs.
+func nextPoolSize(cur, lo, hi int, load poolLoad, interval time.Duration) int {
+	n := cur
+	if load.maxWait > slowWait {
+		n = cur + max(1, cur/2)
+	} else {
+		arriving := int(math.Ceil(float64(load.requests) * float64(refillTime) / float64(interval)))
+		want := max(arriving, load.busy+load.queued) + 1 // one spare
+		switch {
+		case want > cur:
+			n = want
+		case want < cur:
+			n = cur - max(1, (cur-want)/4)
+		}
+	}
+	return min(max(n, lo), hi)
+}
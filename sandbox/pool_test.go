@@ -0,0 +1,63 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNextPoolSize(t *testing.T) {
+	const interval = 10 * time.Second
+	for _, tt := range []struct {
+		desc string
+		cur  int
+		load poolLoad
+		want int
+	}{
+		{desc: "idle at minimum", cur: 2, load: poolLoad{}, want: 2},
+		{desc: "idle shrinks gradually", cur: 40, load: poolLoad{}, want: 31},
+		{desc: "idle shrinks by at least one", cur: 4, load: poolLoad{}, want: 3},
+		{desc: "slow waits grow by half", cur: 8, load: poolLoad{maxWait: time.Second}, want: 12},
+		{desc: "slow waits grow by at least one", cur: 1, load: poolLoad{maxWait: time.Second}, want: 2},
+		{desc: "growth is capped", cur: 50, load: poolLoad{maxWait: time.Second}, want: 64},
+		{desc: "request rate", cur: 2, load: poolLoad{requests: 100}, want: 21},
+		{desc: "busy requests", cur: 2, load: poolLoad{requests: 5, busy: 10}, want: 11},
+		{desc: "queued requests", cur: 2, load: poolLoad{requests: 5, busy: 4, queued: 6}, want: 11},
+		{desc: "steady", cur: 11, load: poolLoad{requests: 50}, want: 11},
+	} {
+		got := nextPoolSize(tt.cur, 2, 64, tt.load, interval)
+		if got != tt.want {
+			t.Errorf("%s: nextPoolSize(%d, 2, 64, %+v) = %d; want %d", tt.desc, tt.cur, tt.load, got, tt.want)
+		}
+	}
+}
+
+func TestWorkerLoopShutdown(t *testing.T) {
+	oldRuntime := sandboxRuntime
+	defer func() { sandboxRuntime = oldRuntime }()
+	rt := &fakeRuntime{startErr: errors.New("no container runtime"), started: make(chan string, 1)}
+	sandboxRuntime = rt
+
+	ctx, cancel := context.WithCancel(t.Context())
+	p := newWorkerPool(1, 1, time.Millisecond)
+	p.setTarget(ctx, 1)
+	name := <-rt.started // the worker is now waiting to retry
+	setContainerWanted(name, false)
+
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		p.wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(startRetryDelay / 2):
+		t.Fatalf("worker didn't exit promptly after its context was canceled")
+	}
+}
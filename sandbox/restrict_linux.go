@@ -0,0 +1,37 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// restrictSelf limits the resources available to this process and
+// the binary it runs, and installs a seccomp filter that blocks
+// system calls a playground program has no business making. It's
+// used in contained mode by the process runtime, which doesn't have
+// gVisor to do this.
+func restrictSelf() error {
+	limits := []struct {
+		resource int
+		max      uint64
+	}{
+		{unix.RLIMIT_DATA, uint64(policy.MemoryLimit)},
+		{unix.RLIMIT_FSIZE, uint64(policy.MemoryLimit)},
+		{unix.RLIMIT_NOFILE, 256},
+		{unix.RLIMIT_CORE, 0},
+	}
+	for _, l := range limits {
+		if err := unix.Setrlimit(l.resource, &unix.Rlimit{Cur: l.max, Max: l.max}); err != nil {
+			return fmt.Errorf("setrlimit(%d): %v", l.resource, err)
+		}
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS): %v", err)
+	}
+	return installSeccomp()
+}
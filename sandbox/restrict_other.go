@@ -0,0 +1,16 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+func restrictSelf() error {
+	return errors.New("--restrict is not supported on " + runtime.GOOS)
+}
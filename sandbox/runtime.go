@@ -0,0 +1,211 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Runtime creates the isolated containers that untrusted binaries
+// run in. Each container runs this program in contained mode (see
+// runInGvisor), which reads the binary from stdin and runs it.
+type Runtime interface {
+	// Check reports an error if the runtime can't be used on this host.
+	Check(ctx context.Context) error
+
+	// Prepare does any one-time setup needed before containers are
+	// started, such as pulling the container image.
+	Prepare(ctx context.Context) error
+
+	// Start starts a container with the given name that runs this
+	// program in contained mode with the extra flags in args. The
+	// container's output is written to stdout and stderr. The caller
+	// writes to the returned stdin and waits for the returned command
+	// to exit; canceling it stops the container.
+	Start(name string, args []string, stdout, stderr io.Writer) (cmd *exec.Cmd, stdin io.WriteCloser, err error)
+
+	// Kill forcibly stops the named container, if it's still running.
+	Kill(ctx context.Context, name string) error
+
+	// OOMKilled reports whether the named container, which was
+	// killed, was killed for exceeding its memory limit.
+	OOMKilled(ctx context.Context, name string) bool
+
+	// List returns the names of the play_run_ containers that are
+	// currently running.
+	List(ctx context.Context) ([]string, error)
+}
+
+// sandboxRuntime is the Runtime used to start containers, as selected
+// by the --runtime flag.
+var sandboxRuntime Runtime = newDockerRuntime("")
+
+// newRuntime returns the Runtime with the given name.
+func newRuntime(name string) (Runtime, error) {
+	switch name {
+	case "docker":
+		return newDockerRuntime(*container), nil
+	case "process":
+		return newProcessRuntime()
+	}
+	return nil, fmt.Errorf("unknown runtime %q; want \"docker\" or \"process\"", name)
+}
+
+// dockerRuntime runs each container with Docker, using gVisor's runsc
+// runtime. It's what production uses.
+type dockerRuntime struct {
+	image   string                                     // image that hosts the untrusted binary
+	command func(name string, arg ...string) *exec.Cmd // runs docker
+
+	mu    sync.Mutex
+	oomed map[string]time.Time // containers Docker reported OOM events for, and when
+}
+
+// newDockerRuntime returns a dockerRuntime that runs image. It runs
+// docker with execCommand as it is now. Its OOMKilled method only
+// works while watchOOM runs.
+func newDockerRuntime(image string) *dockerRuntime {
+	return &dockerRuntime{image: image, command: execCommand}
+}
+
+func (r *dockerRuntime) Check(ctx context.Context) error {
+	if out, err := r.command("docker", "version").CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to connect to docker: %v, %s", err, out)
+	}
+	return nil
+}
+
+func (r *dockerRuntime) Prepare(ctx context.Context) error {
+	if out, err := r.command("docker", "pull", r.image).CombinedOutput(); err != nil {
+		return fmt.Errorf("error pulling %s: %v, %s", r.image, err, out)
+	}
+	return nil
+}
+
+func (r *dockerRuntime) Start(name string, args []string, stdout, stderr io.Writer) (*exec.Cmd, io.WriteCloser, error) {
+	dockerArgs := []string{"run",
+		"--name=" + name,
+		"--rm",
+		"--tmpfs=/tmpfs:exec",
+		"-i", // read stdin
+
+		"--runtime=runsc",
+		"--network=none",
+		"--memory=" + fmt.Sprint(policy.MemoryLimit),
+
+		r.image,
+		"--mode=contained",
+	}
+	cmd := r.command("docker", append(dockerArgs, args...)...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+	return cmd, stdin, nil
+}
+
+func (r *dockerRuntime) Kill(ctx context.Context, name string) error {
+	if out, err := r.command("docker", "rm", "--force", name).CombinedOutput(); err != nil {
+		return fmt.Errorf("docker rm %s: %v, %s", name, err, out)
+	}
+	return nil
+}
+
+// oomEventWait is how long OOMKilled waits for Docker's event, which
+// may arrive after the container has exited.
+const oomEventWait = time.Second
+
+func (r *dockerRuntime) OOMKilled(ctx context.Context, name string) bool {
+	deadline := time.Now().Add(oomEventWait)
+	for {
+		r.mu.Lock()
+		_, ok := r.oomed[name]
+		delete(r.oomed, name)
+		r.mu.Unlock()
+		if ok || time.Now().After(deadline) {
+			return ok
+		}
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// watchOOM records the containers that Docker reports were killed
+// by the kernel's OOM killer, which it learns of from their cgroups,
+// until ctx is done.
+func (r *dockerRuntime) watchOOM(ctx context.Context) {
+	for {
+		cmd := r.command("docker", "events", "--filter", "type=container", "--filter", "event=oom", "--format", "{{.Actor.Attributes.name}}")
+		out, err := cmd.StdoutPipe()
+		if err == nil {
+			err = cmd.Start()
+		}
+		if err == nil {
+			stop := context.AfterFunc(ctx, func() { cmd.Process.Kill() })
+			sc := bufio.NewScanner(out)
+			for sc.Scan() {
+				r.recordOOM(strings.TrimSpace(sc.Text()), time.Now())
+			}
+			err = cmd.Wait()
+			stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("watching for OOM events: docker events: %v; restarting", err)
+		select {
+		case <-time.After(startRetryDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// recordOOM records that Docker reported an OOM event for the named
+// container at time now, and forgets old events that weren't asked
+// about.
+func (r *dockerRuntime) recordOOM(name string, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.oomed == nil {
+		r.oomed = make(map[string]time.Time)
+	}
+	for n, t := range r.oomed {
+		if now.Sub(t) > time.Minute {
+			delete(r.oomed, n)
+		}
+	}
+	r.oomed[name] = now
+}
+
+func (r *dockerRuntime) List(ctx context.Context) ([]string, error) {
+	cs, err := listDockerContainers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, c := range cs {
+		if c.Names != "" {
+			names = append(names, c.Names)
+		}
+	}
+	return names, nil
+}
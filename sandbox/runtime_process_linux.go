@@ -0,0 +1,144 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"syscall"
+)
+
+// processRuntime runs each container as a child process in its own
+// user, mount, PID, network, IPC and UTS namespaces. In contained
+// mode the child also applies rlimits and a seccomp filter before
+// running the binary (see restrictSelf).
+//
+// It doesn't need Docker or gVisor, so it can be used on development
+// machines and in CI, but it's much weaker isolation than gVisor and
+// must not be used to run untrusted code in production.
+type processRuntime struct {
+	exe string // path to this program
+
+	mu    sync.Mutex
+	procs map[string]*processContainer // keyed by container name
+}
+
+type processContainer struct {
+	proc *os.Process
+	dir  string // working directory, removed once proc exits
+}
+
+func newProcessRuntime() (*processRuntime, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("process runtime: %v", err)
+	}
+	return &processRuntime{exe: exe, procs: map[string]*processContainer{}}, nil
+}
+
+// sysProcAttr returns the attributes for starting a process in new
+// namespaces, as root inside them and the current user outside.
+func (r *processRuntime) sysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID |
+			syscall.CLONE_NEWNET | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS,
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getuid(), Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: os.Getgid(), Size: 1}},
+		Pdeathsig:   syscall.SIGKILL,
+	}
+}
+
+func (r *processRuntime) Check(ctx context.Context) error {
+	// Unprivileged user namespaces are disabled on some hosts, so
+	// make sure we can create them.
+	truePath, err := exec.LookPath("true")
+	if err != nil {
+		return fmt.Errorf("process runtime: %v", err)
+	}
+	cmd := exec.CommandContext(ctx, truePath)
+	cmd.SysProcAttr = r.sysProcAttr()
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("process runtime: can't create namespaces: %v", err)
+	}
+	return nil
+}
+
+func (r *processRuntime) Prepare(ctx context.Context) error { return nil }
+
+func (r *processRuntime) Start(name string, args []string, stdout, stderr io.Writer) (*exec.Cmd, io.WriteCloser, error) {
+	dir, err := os.MkdirTemp("", name)
+	if err != nil {
+		return nil, nil, err
+	}
+	cmd := execCommand(r.exe, append([]string{
+		"--mode=contained",
+		"--restrict",
+		"--workdir=" + dir,
+		"--memory-limit=" + fmt.Sprint(policy.MemoryLimit),
+	}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = []string{"HOME=" + dir, "TMPDIR=" + dir}
+	cmd.SysProcAttr = r.sysProcAttr()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.procs[name] = &processContainer{proc: cmd.Process, dir: dir}
+	return cmd, stdin, nil
+}
+
+func (r *processRuntime) Kill(ctx context.Context, name string) error {
+	r.mu.Lock()
+	pc, ok := r.procs[name]
+	r.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	// The contained process is PID 1 of its namespace, so killing it
+	// kills everything it started too.
+	if err := pc.proc.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return err
+	}
+	return nil
+}
+
+// OOMKilled reports false: the process runtime limits memory with
+// RLIMIT_DATA, so allocations fail instead, and the Go runtime reports
+// it.
+func (r *processRuntime) OOMKilled(ctx context.Context, name string) bool { return false }
+
+func (r *processRuntime) List(ctx context.Context) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var names []string
+	for name, pc := range r.procs {
+		// Containers are waited on by their owners, so a process
+		// that's done has exited and been reaped; forget it.
+		if err := pc.proc.Signal(syscall.Signal(0)); errors.Is(err, os.ErrProcessDone) {
+			os.RemoveAll(pc.dir)
+			delete(r.procs, name)
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
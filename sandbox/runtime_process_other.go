@@ -0,0 +1,16 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+func newProcessRuntime() (Runtime, error) {
+	return nil, errors.New("the process runtime is not supported on " + runtime.GOOS)
+}
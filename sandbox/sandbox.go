@@ -5,7 +5,8 @@
 // The sandbox program is an HTTP server that receives untrusted
 // linux/amd64 binaries in a POST request and then executes them in
 // a gvisor sandbox using Docker, returning the output as a response
-// to the POST.
+// to the POST. For development, --runtime=process runs them with
+// Linux namespaces, rlimits and seccomp instead.
 //
 // It's part of the Go playground (https://play.golang.org/).
 package main
@@ -15,17 +16,20 @@ import (
 	"bytes"
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
-	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
@@ -41,25 +45,34 @@ import (
 )
 
 var (
-	listenAddr = flag.String("listen", ":80", "HTTP server listen address. Only applicable when --mode=server")
-	mode       = flag.String("mode", "server", "Whether to run in \"server\" mode or \"contained\" mode. The contained mode is used internally by the server mode.")
-	dev        = flag.Bool("dev", false, "run in dev mode (show help messages)")
-	numWorkers = flag.Int("workers", runtime.NumCPU(), "number of parallel gvisor containers to pre-spin up & let run concurrently")
-	container  = flag.String("untrusted-container", "gcr.io/golang-org/playground-sandbox-gvisor:latest", "container image name that hosts the untrusted binary under gvisor")
+	listenAddr   = flag.String("listen", ":80", "HTTP server listen address. Only applicable when --mode=server")
+	mode         = flag.String("mode", "server", "Whether to run in \"server\" mode or \"contained\" mode. The contained mode is used internally by the server mode.")
+	dev          = flag.Bool("dev", false, "run in dev mode (show help messages)")
+	numWorkers   = flag.Int("workers", runtime.NumCPU(), "maximum number of parallel gvisor containers to pre-spin up & let run concurrently")
+	minWorkers   = flag.Int("min-workers", 1, "minimum number of gvisor containers to keep pre-spun up, even when idle")
+	startEvery   = flag.Duration("container-start-interval", 100*time.Millisecond, "minimum time between starting containers")
+	authKeyFile  = flag.String("auth-key-file", "", "file containing the key shared with the frontend to authenticate /run requests. Required unless --dev is set.")
+	leakGrace    = flag.Duration("leak-grace", time.Minute, "how long a container can be unexpectedly running before it's removed")
+	maxClient    = flag.Int("max-client-runs", 4, "maximum number of /run requests from one client that may be running or queued at once")
+	container    = flag.String("untrusted-container", "gcr.io/golang-org/playground-sandbox-gvisor:latest", "container image name that hosts the untrusted binary under gvisor")
+	policyFile   = flag.String("policy", "", "JSON file of resource limits to use instead of the defaults. Limits set by flags take precedence.")
+	debugKeyFile = flag.String("debug-key-file", "", "file containing the token that authenticates requests to /debug/containers. If empty, the page is only served with --dev.")
+	drainDelay   = flag.Duration("drain-delay", 20*time.Second, "on SIGTERM or SIGINT, how long to fail readiness checks before refusing new connections, so that frontends stop sending requests first")
+	runtimeName  = flag.String("runtime", "docker", "How to run containers: \"docker\" uses Docker with gVisor (runsc); \"process\" uses Linux namespaces, rlimits and seccomp, and is only for development.")
+
+	// Flags used in contained mode.
+	workdir  = flag.String("workdir", "/tmpfs", "In contained mode, the directory to write the binary to.")
+	restrict = flag.Bool("restrict", false, "In contained mode, apply rlimits and a seccomp filter before running the binary.")
 )
 
-const (
-	maxBinarySize    = 100 << 20
-	runTimeout       = 5 * time.Second
-	maxOutputSize    = 100 << 20
-	memoryLimitBytes = 100 << 20
-)
+// policy holds the resource limits enforced by the sandbox. In
+// contained mode, the server passes down the limits that apply there.
+var policy = sandboxtypes.DefaultPolicy()
 
 var (
-	startTimeout     = 30 * time.Second
-	execCommand      = exec.Command
-	errTooMuchOutput = errors.New("Output too large")
-	errRunTimeout    = errors.New("timeout running program")
+	startTimeout  = 30 * time.Second
+	execCommand   = exec.Command
+	errRunTimeout = errors.New("timeout running program")
 )
 
 // containedStartMessage is the first thing written to stdout by the
@@ -72,26 +85,73 @@ const containedStartMessage = "golang-gvisor-process-started\n"
 // but before it's run.
 var containedStderrHeader = []byte("golang-gvisor-process-got-input\n")
 
+// usageHeader returns the header written to stderr by the
+// gvisor-contained process after the untrusted binary exits, followed
+// by the JSON sandboxtypes.Usage of the run and a newline. It includes
+// the run's nonce, which the binary doesn't know, so that the binary
+// can't forge the trailer.
+func usageHeader(nonce string) []byte {
+	return []byte("golang-gvisor-process-usage-" + nonce + ":")
+}
+
+// artifactsHeader returns the header written to stderr by the
+// gvisor-contained process last, if the untrusted binary wrote any
+// files to its sandboxtypes.ArtifactDir, followed by their JSON
+// artifactsTrailer. Like usageHeader, it includes the run's nonce, so
+// that the binary can't make its own output be taken for artifacts.
+func artifactsHeader(nonce string) []byte {
+	return []byte("golang-gvisor-process-artifacts-" + nonce + ":")
+}
+
+// artifactsTrailer is the JSON written after artifactsHeader.
+type artifactsTrailer struct {
+	Artifacts []sandboxtypes.Artifact `json:"artifacts"`
+	Truncated bool                    `json:"truncated"`
+}
+
 var (
 	readyContainer chan *Container
-	runSem         chan struct{}
+
+	// runVerifier authenticates /run requests. It's nil if they
+	// aren't authenticated, which is only allowed in dev mode.
+	runVerifier *sandboxtypes.RunVerifier
 )
 
 type Container struct {
-	name string
+	name  string
+	nonce string // random; marks the trailers of the container's run
 
-	stdin  io.WriteCloser
-	stdout *limitedWriter
-	stderr *limitedWriter
+	stdin     io.WriteCloser
+	stdout    *limitedWriter
+	stderr    *limitedWriter
+	artifacts *limitedWriter // the artifactsTrailer, split from stderr
 
 	cmd       *exec.Cmd
 	cancelCmd context.CancelFunc
 
-	waitErr chan error // 1-buffered; receives error from WaitOrStop(..., cmd, ...)
+	waitErr chan error    // 1-buffered; receives error from WaitOrStop(..., cmd, ...)
+	exited  chan struct{} // closed when cmd exits
+
+	overflow     chan struct{} // closed when stdout or stderr exceeds its limit
+	overflowOnce sync.Once
+
+	// For /debug/containers; state and runStart are guarded by
+	// liveContainers.
+	created  time.Time
+	state    string
+	runStart time.Time // when the current run started
+}
+
+// outputFull is called by the container's limitedWriters when they
+// first start dropping output.
+func (c *Container) outputFull() {
+	c.overflowOnce.Do(func() { close(c.overflow) })
 }
 
 func (c *Container) Close() {
 	setContainerWanted(c.name, false)
+	c.setState(containerClosing)
+	defer c.untrack()
 
 	c.cancelCmd()
 	if err := c.Wait(); err != nil {
@@ -108,7 +168,16 @@ func (c *Container) Wait() error {
 var httpServer *http.Server
 
 func main() {
+	policy.RegisterFlags(flag.CommandLine)
 	flag.Parse()
+	if *policyFile != "" {
+		if err := policy.LoadFile(*policyFile, flag.CommandLine); err != nil {
+			log.Fatalf("error loading policy: %v", err)
+		}
+	}
+	if err := policy.Validate(); err != nil {
+		log.Fatalf("invalid policy: %v", err)
+	}
 	if *mode == "contained" {
 		runInGvisor()
 		panic("runInGvisor didn't exit")
@@ -119,9 +188,39 @@ func main() {
 	}
 	log.Printf("Go playground sandbox starting.")
 
+	if *authKeyFile != "" {
+		key, err := sandboxtypes.LoadAuthKey(*authKeyFile)
+		if err != nil {
+			log.Fatalf("error loading auth key: %v", err)
+		}
+		runVerifier = sandboxtypes.NewRunVerifier(key)
+	} else if !*dev {
+		log.Fatalf("--auth-key-file is required unless --dev is set")
+	} else {
+		log.Printf("No --auth-key-file; /run requests will not be authenticated.")
+	}
+	if *debugKeyFile != "" {
+		key, err := sandboxtypes.LoadAuthKey(*debugKeyFile)
+		if err != nil {
+			log.Fatalf("error loading debug key: %v", err)
+		}
+		debugKey = key
+	}
+
+	rt, err := newRuntime(*runtimeName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sandboxRuntime = rt
+
 	readyContainer = make(chan *Container)
-	runSem = make(chan struct{}, *numWorkers)
-	go handleSignals()
+	if *maxClient < 1 {
+		log.Fatal("--max-client-runs must be at least 1")
+	}
+	runSched = newFairScheduler(*numWorkers, *maxClient)
+	ctx, stop := context.WithCancel(context.Background())
+	shutdownDone := make(chan struct{})
+	go handleSignals(stop, shutdownDone)
 
 	mux := http.NewServeMux()
 
@@ -136,27 +235,40 @@ func main() {
 		defer ms.Stop()
 	}
 
-	if out, err := execCommand("docker", "version").CombinedOutput(); err != nil {
-		log.Fatalf("failed to connect to docker: %v, %s", err, out)
+	if err := sandboxRuntime.Check(context.Background()); err != nil {
+		log.Fatal(err)
 	}
 	if *dev {
 		log.Printf("Running in dev mode; container published to host at: http://localhost:8080/")
 		log.Printf("Run a binary with: curl -v --data-binary @/home/bradfitz/hello http://localhost:8080/run\n")
 	} else {
-		if out, err := execCommand("docker", "pull", *container).CombinedOutput(); err != nil {
-			log.Fatalf("error pulling %s: %v, %s", *container, err, out)
+		if err := sandboxRuntime.Prepare(context.Background()); err != nil {
+			log.Fatal(err)
 		}
 		log.Printf("Listening on %s", *listenAddr)
 	}
 
-	mux.Handle("/health", ochttp.WithRouteTag(http.HandlerFunc(healthHandler), "/health"))
-	mux.Handle("/healthz", ochttp.WithRouteTag(http.HandlerFunc(healthHandler), "/healthz"))
+	// Liveness checks. /health and /healthz are kept for existing
+	// health checks, which should only restart broken sandboxes.
+	mux.Handle("/health", ochttp.WithRouteTag(http.HandlerFunc(livenessHandler), "/health"))
+	mux.Handle("/healthz", ochttp.WithRouteTag(http.HandlerFunc(livenessHandler), "/healthz"))
+	mux.Handle("/livez", ochttp.WithRouteTag(http.HandlerFunc(livenessHandler), "/livez"))
+	mux.Handle("/readyz", ochttp.WithRouteTag(http.HandlerFunc(readinessHandler), "/readyz"))
 	mux.Handle("/", ochttp.WithRouteTag(http.HandlerFunc(rootHandler), "/"))
 	mux.Handle("/run", ochttp.WithRouteTag(http.HandlerFunc(runHandler), "/run"))
+	mux.Handle("/debug/containers", ochttp.WithRouteTag(http.HandlerFunc(debugContainersHandler), "/debug/containers"))
 
-	makeWorkers()
-	go internal.PeriodicallyDo(context.Background(), 10*time.Second, func(ctx context.Context, _ time.Time) {
-		countDockerContainers(ctx)
+	reapAllContainers(context.Background())
+	if *minWorkers < 1 || *minWorkers > *numWorkers {
+		log.Fatalf("--min-workers must be between 1 and --workers (%d)", *numWorkers)
+	}
+	pool = newWorkerPool(*minWorkers, *numWorkers, *startEvery)
+	go pool.run(ctx)
+	if dr, ok := sandboxRuntime.(*dockerRuntime); ok {
+		go dr.watchOOM(ctx)
+	}
+	go internal.PeriodicallyDo(ctx, 10*time.Second, func(ctx context.Context, now time.Time) {
+		checkContainers(ctx, now)
 	})
 
 	trace.ApplyConfig(trace.Config{DefaultSampler: trace.NeverSample()})
@@ -164,7 +276,10 @@ func main() {
 		Addr:    *listenAddr,
 		Handler: &ochttp.Handler{Handler: mux},
 	}
-	log.Fatal(httpServer.ListenAndServe())
+	if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-shutdownDone
 }
 
 // dockerContainer is the structure of each line output from docker ps.
@@ -177,21 +292,84 @@ type dockerContainer struct {
 	Names string `json:"Names"`
 }
 
-// countDockerContainers records the metric for the current number of docker containers.
-// It also records the count of any unwanted containers.
-func countDockerContainers(ctx context.Context) {
-	cs, err := listDockerContainers(ctx)
+// checkContainers records the metric for the current number of containers.
+// It also records the count of any unwanted containers, and removes
+// those that have been unwanted for longer than --leak-grace.
+func checkContainers(ctx context.Context, now time.Time) {
+	names, err := sandboxRuntime.List(ctx)
+	if err != nil {
+		log.Printf("Error counting containers: %v", err)
+	}
+	stats.Record(ctx, mContainers.M(int64(len(names))))
+	var unwanted []string
+	for _, name := range names {
+		if !isContainerWanted(name) {
+			unwanted = append(unwanted, name)
+		}
+	}
+	stats.Record(ctx, mUnwantedContainers.M(int64(len(unwanted))))
 	if err != nil {
-		log.Printf("Error counting docker containers: %v", err)
+		// Don't forget when containers became unwanted just
+		// because we couldn't list them this time.
+		return
 	}
-	stats.Record(ctx, mContainers.M(int64(len(cs))))
-	var unwantedCount int64
-	for _, c := range cs {
-		if c.Names != "" && !isContainerWanted(c.Names) {
-			unwantedCount++
+	reapContainers(ctx, unwanted, now, *leakGrace)
+}
+
+// unwantedSince is when each unwanted container was first seen by
+// reapContainers. It's only used by the checkContainers loop.
+var unwantedSince = map[string]time.Time{}
+
+// reapContainers removes the containers in unwanted that have been
+// unwanted for at least grace. Containers that are closed normally
+// can be listed for a little while as they shut down, so they're
+// given time to go away by themselves.
+func reapContainers(ctx context.Context, unwanted []string, now time.Time, grace time.Duration) {
+	since := make(map[string]time.Time)
+	for _, name := range unwanted {
+		t, ok := unwantedSince[name]
+		if !ok {
+			t = now
+		}
+		if now.Sub(t) < grace {
+			since[name] = t
+			continue
+		}
+		if err := reapContainer(ctx, name, fmt.Sprintf("unwanted for %v", now.Sub(t).Round(time.Second))); err != nil {
+			since[name] = t // try again next time
 		}
 	}
-	stats.Record(ctx, mUnwantedContainers.M(unwantedCount))
+	unwantedSince = since
+}
+
+// reapAllContainers removes any containers left running by a
+// previous sandbox process. It's called at startup, before any
+// containers are wanted.
+func reapAllContainers(ctx context.Context) {
+	names, err := sandboxRuntime.List(ctx)
+	if err != nil {
+		log.Printf("Error listing containers at startup: %v", err)
+		return
+	}
+	for _, name := range names {
+		reapContainer(ctx, name, "left running by a previous process")
+	}
+}
+
+// reapContainer forcibly removes the named container, logging why and
+// counting it in the metrics.
+func reapContainer(ctx context.Context, name, why string) error {
+	status := "success"
+	err := sandboxRuntime.Kill(ctx, name)
+	if err != nil {
+		status = "error"
+		log.Printf("Error reaping container %q (%s): %v", name, why, err)
+	} else {
+		log.Printf("Reaped container %q: %s", name, why)
+	}
+	// Ignore error. The only error can be invalid tag key or value length, which we know are safe.
+	_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(kContainerReapStatus, status)}, mReapedContainers.M(1))
+	return err
 }
 
 // listDockerContainers returns the current running play_run containers reported by docker.
@@ -231,77 +409,6 @@ func parseDockerContainers(b []byte) ([]dockerContainer, error) {
 	return containers, nil
 }
 
-func handleSignals() {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGINT)
-	s := <-c
-	log.Fatalf("closing on signal %d: %v", s, s)
-}
-
-var healthStatus struct {
-	sync.Mutex
-	lastCheck time.Time
-	lastVal   error
-}
-
-func getHealthCached() error {
-	healthStatus.Lock()
-	defer healthStatus.Unlock()
-	const recentEnough = 5 * time.Second
-	if healthStatus.lastCheck.After(time.Now().Add(-recentEnough)) {
-		return healthStatus.lastVal
-	}
-
-	err := checkHealth()
-	if healthStatus.lastVal == nil && err != nil {
-		// On transition from healthy to unhealthy, close all
-		// idle HTTP connections so clients with them open
-		// don't reuse them. TODO: remove this if/when we
-		// switch away from direct load balancing between
-		// frontends and this sandbox backend.
-		httpServer.SetKeepAlivesEnabled(false) // side effect of closing all idle ones
-		httpServer.SetKeepAlivesEnabled(true)  // and restore it back to normal
-	}
-	healthStatus.lastVal = err
-	healthStatus.lastCheck = time.Now()
-	return err
-}
-
-// checkHealth does a health check, without any caching. It's called via
-// getHealthCached.
-func checkHealth() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	c, err := getContainer(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get a sandbox container: %v", err)
-	}
-	// TODO: execute something too? for now we just check that sandboxed containers
-	// are available.
-	closed := make(chan struct{})
-	go func() {
-		c.Close()
-		close(closed)
-	}()
-	select {
-	case <-closed:
-		// success.
-		return nil
-	case <-ctx.Done():
-		return fmt.Errorf("timeout closing sandbox container")
-	}
-}
-
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	// TODO: split into liveness & readiness checks?
-	if err := getHealthCached(); err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Fprintf(w, "health check failure: %v\n", err)
-		return
-	}
-	io.WriteString(w, "OK\n")
-}
-
 func rootHandler(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path != "/" {
 		http.NotFound(w, r)
@@ -311,17 +418,31 @@ func rootHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // processMeta is the JSON sent to the gvisor container before the untrusted binary.
-// It currently contains only the arguments to pass to the binary.
-// It might contain environment or other things later.
+// It contains the arguments, environment and standard input of the
+// binary, the data files to put in its working directory, and how long
+// it may run.
 type processMeta struct {
-	Args []string `json:"args"`
+	Args       []string          `json:"args"`
+	Env        []string          `json:"env,omitempty"`
+	Stdin      []byte            `json:"stdin,omitempty"`
+	Files      map[string][]byte `json:"files,omitempty"`      // keyed by slash-separated path
+	MaxRunTime time.Duration     `json:"maxRunTime,omitempty"` // if positive, lowers policy.MaxRunTime
+	Nonce      string            `json:"nonce"`                // marks the trailers written after the binary exits
+}
+
+// runTime returns the time the binary may run.
+func (m *processMeta) runTime() time.Duration {
+	if m.MaxRunTime > 0 {
+		return min(m.MaxRunTime, policy.MaxRunTime)
+	}
+	return policy.MaxRunTime
 }
 
 // runInGvisor is run when we're now inside gvisor. We have no network
 // at this point. We can read our binary in from stdin and then run
 // it.
 func runInGvisor() {
-	const binPath = "/tmpfs/play"
+	binPath := filepath.Join(*workdir, "play")
 	if _, err := io.WriteString(os.Stdout, containedStartMessage); err != nil {
 		log.Fatalf("writing to stdout: %v", err)
 	}
@@ -345,48 +466,112 @@ func runInGvisor() {
 		log.Fatalf("error decoding JSON meta: %v", err)
 	}
 
+	// The binary runs in its own directory, with any data files laid
+	// out in it, so that it can read them by relative path.
+	runDir := filepath.Join(*workdir, "run")
+	if err := writeDataFiles(runDir, meta.Files); err != nil {
+		log.Fatalf("writing data files: %v", err)
+	}
+	artifactDir := filepath.Join(runDir, sandboxtypes.ArtifactDir)
+	if err := os.MkdirAll(artifactDir, 0755); err != nil {
+		log.Fatalf("creating artifact directory: %v", err)
+	}
+
+	if *restrict {
+		if err := restrictSelf(); err != nil {
+			log.Fatalf("restricting contained process: %v", err)
+		}
+	}
+
 	if _, err := os.Stderr.Write(containedStderrHeader); err != nil {
 		log.Fatalf("writing header to stderr: %v", err)
 	}
 
+	start := time.Now()
 	cmd := execCommand(binPath)
 	cmd.Args = append(cmd.Args, meta.Args...)
+	cmd.Dir = runDir
+	cmd.Env = append(os.Environ(), meta.Env...)
+	cmd.Stdin = bytes.NewReader(meta.Stdin)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Start(); err != nil {
 		log.Fatalf("cmd.Start(): %v", err)
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), runTimeout-500*time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), meta.runTime()-500*time.Millisecond)
 	defer cancel()
 	if err = internal.WaitOrStop(ctx, cmd, os.Interrupt, 250*time.Millisecond); err != nil {
 		if errors.Is(err, context.DeadlineExceeded) {
 			fmt.Fprintln(os.Stderr, "timeout running program")
 		}
 	}
+	if ps := cmd.ProcessState; ps != nil {
+		usageJSON, _ := json.Marshal(&sandboxtypes.Usage{
+			WallTime:   time.Since(start),
+			UserTime:   ps.UserTime(),
+			SystemTime: ps.SystemTime(),
+			MaxRSS:     maxRSS(ps),
+		})
+		fmt.Fprintf(os.Stderr, "%s%s\n", usageHeader(meta.Nonce), usageJSON)
+	}
+	if t := collectArtifacts(artifactDir, policy.MaxArtifacts, policy.MaxArtifactsSize); len(t.Artifacts) > 0 || t.Truncated {
+		trailerJSON, _ := json.Marshal(t)
+		fmt.Fprintf(os.Stderr, "%s%s\n", artifactsHeader(meta.Nonce), trailerJSON)
+	}
 	os.Exit(errExitCode(err))
 	return
 }
 
-func makeWorkers() {
-	ctx := context.Background()
-	log.Printf("makeWorkers: starting %d workers", *numWorkers)
-	stats.Record(ctx, mMaxContainers.M(int64(*numWorkers)))
-	for i := 0; i < *numWorkers; i++ {
-		go workerLoop(ctx, i)
-	}
+// collectArtifacts returns the regular files under dir, in lexical
+// order, up to maxFiles files and maxSize bytes in total. Files beyond the
+// limits are left out, and the trailer is marked truncated.
+func collectArtifacts(dir string, maxFiles int, maxSize int64) *artifactsTrailer {
+	t := new(artifactsTrailer)
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if len(t.Artifacts) >= maxFiles || size+info.Size() > maxSize {
+			t.Truncated = true
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || int64(len(data)) > maxSize-size {
+			t.Truncated = true
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		t.Artifacts = append(t.Artifacts, sandboxtypes.Artifact{Name: filepath.ToSlash(rel), Data: data})
+		size += int64(len(data))
+		return nil
+	})
+	return t
 }
 
-func workerLoop(ctx context.Context, id int) {
-	log.Printf("workerLoop %d: started", id)
-	for {
-		c, err := startContainer(ctx)
-		if err != nil {
-			log.Printf("workerLoop %d: error starting container: %v", id, err)
-			time.Sleep(5 * time.Second)
-			continue
+// writeDataFiles creates dir and writes files, keyed by slash-separated
+// path, into it.
+func writeDataFiles(dir string, files map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, data := range files {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return fmt.Errorf("invalid data file name %q", name)
+		}
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(p, data, 0644); err != nil {
+			return err
 		}
-		readyContainer <- c
 	}
+	return nil
 }
 
 func randHex(n int) string {
@@ -405,9 +590,7 @@ var (
 
 // setContainerWanted records whether a named container is wanted or
 // not. Any unwanted containers are cleaned up asynchronously as a
-// sanity check against leaks.
-//
-// TODO(bradfitz): add leak checker (background docker ps loop)
+// sanity check against leaks (see checkContainers).
 func setContainerWanted(name string, wanted bool) {
 	wantedMu.Lock()
 	defer wantedMu.Unlock()
@@ -437,8 +620,10 @@ func getContainer(ctx context.Context) (_ *Container, err error) {
 				status = "error"
 			}
 		}
+		wait := time.Since(start)
 		_ = stats.RecordWithTags(context.Background(), []tag.Mutator{tag.Upsert(kContainerWaitStatus, status)},
-			mContainerWaitLatency.M(float64(time.Since(start))/float64(time.Millisecond)))
+			mContainerWaitLatency.M(float64(wait)/float64(time.Millisecond)))
+		pool.observeWait(wait)
 	}()
 
 	select {
@@ -463,44 +648,39 @@ func startContainer(ctx context.Context) (_ *Container, err error) {
 
 	name := "play_run_" + randHex(8)
 	setContainerWanted(name, true)
-	cmd := execCommand("docker", "run",
-		"--name="+name,
-		"--rm",
-		"--tmpfs=/tmpfs:exec",
-		"-i", // read stdin
-
-		"--runtime=runsc",
-		"--network=none",
-		"--memory="+fmt.Sprint(memoryLimitBytes),
-
-		*container,
-		"--mode=contained")
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		return nil, err
-	}
 	pr, pw := io.Pipe()
-	stdout := &limitedWriter{dst: &bytes.Buffer{}, n: maxOutputSize + int64(len(containedStartMessage))}
-	stderr := &limitedWriter{dst: &bytes.Buffer{}, n: maxOutputSize}
-	cmd.Stdout = &switchWriter{switchAfter: []byte(containedStartMessage), dst1: pw, dst2: stdout}
-	cmd.Stderr = stderr
-	if err := cmd.Start(); err != nil {
-		return nil, err
-	}
-
 	ctx, cancel := context.WithCancel(ctx)
 	c := &Container{
 		name:      name,
-		stdin:     stdin,
-		stdout:    stdout,
-		stderr:    stderr,
-		cmd:       cmd,
+		nonce:     randHex(32),
 		cancelCmd: cancel,
 		waitErr:   make(chan error, 1),
+		exited:    make(chan struct{}),
+		overflow:  make(chan struct{}),
 	}
+	c.stdout = &limitedWriter{dst: &bytes.Buffer{}, n: policy.MaxOutputSize, full: c.outputFull}
+	c.stderr = &limitedWriter{dst: &bytes.Buffer{}, n: policy.MaxOutputSize, full: c.outputFull}
+	// The artifacts are base64-encoded in the trailer; allow for that.
+	c.artifacts = &limitedWriter{dst: &bytes.Buffer{}, n: 2*policy.MaxArtifactsSize + 64<<10}
+	stdout := &switchWriter{switchAfter: []byte(containedStartMessage), dst1: pw, dst2: c.stdout}
+	stderr := &switchWriter{switchAfter: artifactsHeader(c.nonce), dst1: c.stderr, dst2: c.artifacts}
+	args := []string{
+		"--max-run-time=" + policy.MaxRunTime.String(),
+		"--max-artifacts=" + strconv.Itoa(policy.MaxArtifacts),
+		"--max-artifacts-size=" + strconv.FormatInt(policy.MaxArtifactsSize, 10),
+	}
+	cmd, stdin, err := sandboxRuntime.Start(name, args, stdout, stderr)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	c.cmd, c.stdin = cmd, stdin
+	c.track()
+
 	go func() {
 		err := internal.WaitOrStop(ctx, cmd, os.Interrupt, 250*time.Millisecond)
 		c.waitErr <- err
+		close(c.exited)
 		pw.CloseWithError(err)
 	}()
 	defer func() {
@@ -526,7 +706,7 @@ func startContainer(ctx context.Context) (_ *Container, err error) {
 	defer timer.Stop()
 	select {
 	case <-timer.C:
-		err := fmt.Errorf("timeout starting container %q", name)
+		err = fmt.Errorf("timeout starting container %q", name)
 		cancel()
 		<-startErr
 		return nil, err
@@ -538,6 +718,7 @@ func startContainer(ctx context.Context) (_ *Container, err error) {
 	}
 
 	log.Printf("started container %q", name)
+	c.setState(containerReady)
 	return c, nil
 }
 
@@ -564,22 +745,54 @@ func runHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Bound the number of requests being processed at once.
-	// (Before we slurp the binary into memory)
-	select {
-	case runSem <- struct{}{}:
-	case <-r.Context().Done():
-		return
+	// Reject requests that aren't signed before anything else, so
+	// that they can't make us read a large body or take a run slot.
+	if runVerifier != nil {
+		if err := runVerifier.CheckHeaders(r, time.Now()); err != nil {
+			log.Printf("rejected unauthenticated /run request from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
 	}
-	defer func() { <-runSem }()
 
-	bin, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBinarySize))
+	// Allow a little extra for the framing and metadata. The body is
+	// hashed as it's read, to check its signature.
+	h := sha256.New()
+	body, err := io.ReadAll(io.TeeReader(http.MaxBytesReader(w, r.Body, policy.MaxBinarySize+policy.MaxDataSize+64<<10), h))
 	if err != nil {
 		log.Printf("failed to read request body: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	logf("read %d bytes", len(bin))
+	logf("read %d bytes", len(body))
+
+	if runVerifier != nil {
+		if err := runVerifier.VerifyDigest(r, h.Sum(nil), time.Now()); err != nil {
+			log.Printf("rejected unauthenticated /run request from %s: %v", r.RemoteAddr, err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	// Bound the number of requests being processed at once, sharing
+	// them fairly between clients.
+	release, err := runSched.acquire(r.Context(), r.Header.Get(sandboxtypes.ClientHeader))
+	if errors.Is(err, errClientBusy) {
+		stats.Record(r.Context(), mClientRejections.M(1))
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+	if err != nil {
+		return
+	}
+	defer release()
+
+	rr, err := parseRunRequest(r.Header, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	c, err := getContainer(r.Context())
 	if err != nil {
@@ -587,98 +800,197 @@ func runHandler(w http.ResponseWriter, r *http.Request) {
 			log.Printf("getContainer, client side cancellation: %v", cerr)
 			return
 		}
+		recordRun(r.Context(), outcomeError, nil, len(rr.Binary), 0)
 		http.Error(w, "failed to get container", http.StatusInternalServerError)
 		log.Printf("failed to get container: %v", err)
 		return
 	}
 	logf("got container %s", c.name)
 
-	ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+	if rr.MaxOutputSize > 0 {
+		// Nothing has been written to these yet: the binary
+		// hasn't been sent to the container.
+		c.stdout.n = min(c.stdout.n, rr.MaxOutputSize)
+		c.stderr.n = min(c.stderr.n, rr.MaxOutputSize)
+	}
+	meta := processMeta{
+		Args:       rr.Args,
+		Env:        rr.Env,
+		Stdin:      rr.Stdin,
+		Files:      rr.Files,
+		MaxRunTime: rr.MaxRunTime,
+	}
+	runStart := time.Now()
+	// The run isn't tied to r's context: it's bounded by its
+	// own time limit.
+	res, err := runInContainer(context.Background(), c, rr.Binary, meta, logf)
+	recordRun(r.Context(), runOutcome(res, err), res, len(rr.Binary), time.Since(runStart))
+	if errors.Is(err, errRunTimeout) {
+		sendError(w, r, "timeout running program")
+		return
+	}
+	if err != nil {
+		log.Printf("running in container %s: %v", c.name, err)
+		http.Error(w, "unknown error during docker run", http.StatusInternalServerError)
+		return
+	}
+	sendResponse(w, r, res)
+}
+
+// parseRunRequest returns the request in the body of a /run request
+// with the given header. The body is either framed, or is just the
+// binary, with its arguments in X-Argument headers.
+func parseRunRequest(h http.Header, body []byte) (*sandboxtypes.RunRequest, error) {
+	rr := &sandboxtypes.RunRequest{Binary: body, Args: h["X-Argument"]}
+	if sandboxtypes.IsFramesContentType(h.Get("Content-Type")) {
+		var err error
+		if rr, err = sandboxtypes.UnmarshalRunRequest(body); err != nil {
+			return nil, err
+		}
+	}
+	if int64(len(rr.Binary)) > policy.MaxBinarySize {
+		return nil, errors.New("binary too large")
+	}
+	size := int64(len(rr.Stdin))
+	for name, data := range rr.Files {
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return nil, fmt.Errorf("invalid data file name %q", name)
+		}
+		size += int64(len(data))
+	}
+	if size > policy.MaxDataSize {
+		return nil, errors.New("data files and stdin too large")
+	}
+	return rr, nil
+}
+
+// runInContainer runs bin in c, which it closes, and returns its
+// output. meta holds bin's arguments and data files. It returns
+// errRunTimeout if bin doesn't finish within meta.runTime(), or
+// before ctx is done.
+func runInContainer(ctx context.Context, c *Container, bin []byte, meta processMeta, logf func(format string, args ...any)) (*sandboxtypes.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, meta.runTime())
 	closed := make(chan struct{})
 	defer func() {
-		logf("leaving handler; about to close container")
+		logf("done running; about to close container")
 		cancel()
 		<-closed
 	}()
 	go func() {
-		<-ctx.Done()
-		if ctx.Err() == context.DeadlineExceeded {
-			logf("timeout")
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				logf("timeout")
+			}
+		case <-c.overflow:
+			// There's no point letting it run any longer.
+			logf("output limit reached")
 		}
 		c.Close()
 		close(closed)
 	}()
-	var meta processMeta
-	meta.Args = r.Header["X-Argument"]
+	c.setState(containerRunning)
+	meta.Nonce = c.nonce
 	metaJSON, _ := json.Marshal(&meta)
 	metaJSON = append(metaJSON, '\n')
 	if _, err := c.stdin.Write(metaJSON); err != nil {
-		log.Printf("failed to write meta to child: %v", err)
-		http.Error(w, "unknown error during docker run", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to write meta to child: %w", err)
 	}
 	if _, err := c.stdin.Write(bin); err != nil {
-		log.Printf("failed to write binary to child: %v", err)
-		http.Error(w, "unknown error during docker run", http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("failed to write binary to child: %w", err)
 	}
 	c.stdin.Close()
 	logf("wrote+closed")
-	err = c.Wait()
+	runStart := time.Now()
+	err := c.Wait()
+	wallTime := time.Since(runStart)
 	select {
 	case <-ctx.Done():
 		// Timed out or canceled before or exactly as Wait returned.
 		// Either way, treat it as a timeout.
-		sendError(w, "timeout running program")
-		return
+		return nil, errRunTimeout
 	default:
 		logf("finished running; about to close container")
 		cancel()
 	}
 	res := &sandboxtypes.Response{}
+	if dropped := c.stdout.dropped + c.stderr.dropped; dropped > 0 {
+		res.Truncated = true
+		res.DroppedBytes = dropped
+	}
 	if err != nil {
-		if c.stderr.n < 0 || c.stdout.n < 0 {
-			// Do not send truncated output, just send the error.
-			sendError(w, errTooMuchOutput.Error())
-			return
-		}
 		var ee *exec.ExitError
-		if !errors.As(err, &ee) {
-			http.Error(w, "unknown error during docker run", http.StatusInternalServerError)
-			return
+		switch {
+		case errors.As(err, &ee):
+			res.ExitCode = ee.ExitCode()
+		case res.Truncated:
+			// We stopped the container when the output limit
+			// was hit; report an exit code no process exits with.
+			res.ExitCode = -1
+		default:
+			return nil, err
 		}
-		res.ExitCode = ee.ExitCode()
 	}
 	res.Stdout = c.stdout.dst.Bytes()
-	res.Stderr = cleanStderr(c.stderr.dst.Bytes())
-	sendResponse(w, res)
+	stderr := bytes.TrimSuffix(cleanStderr(c.stderr.dst.Bytes()), artifactsHeader(c.nonce))
+	res.Stderr, res.Usage = splitUsage(stderr, c.nonce)
+	res.Artifacts, res.ArtifactsTruncated = parseArtifacts(c.artifacts)
+	res.OutOfMemory = outOfMemory(c, res)
+	if res.Usage == nil {
+		// The contained process didn't report usage, so fall back
+		// to what we can measure from out here.
+		res.Usage = &sandboxtypes.Usage{WallTime: wallTime}
+	}
+	res.Usage.StdoutBytes = int64(len(res.Stdout))
+	res.Usage.StderrBytes = int64(len(res.Stderr))
+	res.Usage.TimeLimit = meta.runTime()
+	res.Usage.MemoryLimit = policy.MemoryLimit
+	return res, nil
 }
 
-// limitedWriter is an io.Writer that returns an errTooMuchOutput when the cap (n) is hit.
+// parseArtifacts returns the artifacts in the trailer written to w,
+// and whether any were left out.
+func parseArtifacts(w *limitedWriter) ([]sandboxtypes.Artifact, bool) {
+	if w.dst.Len() == 0 {
+		return nil, false
+	}
+	var t artifactsTrailer
+	if w.dropped > 0 || json.Unmarshal(w.dst.Bytes(), &t) != nil {
+		// The trailer didn't fit, or the binary wrote something
+		// that looked like one. Either way, nothing is usable.
+		return nil, true
+	}
+	return t.Artifacts, t.Truncated
+}
+
+// limitedWriter is an io.Writer that keeps the first n bytes written
+// to it in dst and counts, but discards, the rest.
 type limitedWriter struct {
-	dst *bytes.Buffer
-	n   int64 // max bytes remaining
+	dst     *bytes.Buffer
+	n       int64  // max bytes remaining
+	dropped int64  // bytes discarded after the cap (n) was hit
+	full    func() // if non-nil, called when bytes are first dropped
 }
 
-// Write is an io.Writer function that returns errTooMuchOutput when the cap (n) is hit.
-//
-// Partial data will be written to dst if p is larger than n, but errTooMuchOutput will be returned.
+// Write is an io.Writer function that writes to dst until the cap (n)
+// is hit. It never returns an error for going over the cap, so that
+// the writing process is not blocked; the excess is counted in dropped.
 func (l *limitedWriter) Write(p []byte) (int, error) {
-	defer func() { l.n -= int64(len(p)) }()
-
-	if l.n <= 0 {
-		return 0, errTooMuchOutput
-	}
-
-	if int64(len(p)) > l.n {
-		n, err := l.dst.Write(p[:l.n])
+	keep := min(int64(len(p)), l.n)
+	if keep > 0 {
+		n, err := l.dst.Write(p[:keep])
+		l.n -= int64(n)
 		if err != nil {
 			return n, err
 		}
-		return n, errTooMuchOutput
 	}
-
-	return l.dst.Write(p)
+	if over := int64(len(p)) - keep; over > 0 {
+		if l.dropped == 0 && l.full != nil {
+			l.full()
+		}
+		l.dropped += over
+	}
+	return len(p), nil
 }
 
 // switchWriter writes to dst1 until switchAfter is written, then it writes to dst2.
@@ -722,16 +1034,36 @@ func errExitCode(err error) int {
 	}
 	var ee *exec.ExitError
 	if errors.As(err, &ee) {
+		// A process killed by a signal has no exit code of its own;
+		// report it the way a shell would, so that a binary the
+		// kernel killed looks like a container it killed.
+		if ws, ok := ee.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
 		return ee.ExitCode()
 	}
 	return 1
 }
 
-func sendError(w http.ResponseWriter, errMsg string) {
-	sendResponse(w, &sandboxtypes.Response{Error: errMsg})
+func sendError(w http.ResponseWriter, req *http.Request, errMsg string) {
+	sendResponse(w, req, &sandboxtypes.Response{Error: errMsg})
 }
 
-func sendResponse(w http.ResponseWriter, r *sandboxtypes.Response) {
+// sendResponse sends r in reply to req, framed if req accepts it and
+// as JSON otherwise.
+func sendResponse(w http.ResponseWriter, req *http.Request, r *sandboxtypes.Response) {
+	if sandboxtypes.AcceptsFrames(req.Header.Get("Accept")) {
+		fres, err := r.MarshalFrames()
+		if err != nil {
+			http.Error(w, "error encoding response", http.StatusInternalServerError)
+			log.Printf("framing response: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", sandboxtypes.FramesContentType)
+		w.Header().Set("Content-Length", fmt.Sprint(len(fres)))
+		w.Write(fres)
+		return
+	}
 	jres, err := json.MarshalIndent(r, "", "  ")
 	if err != nil {
 		http.Error(w, "error encoding JSON", http.StatusInternalServerError)
@@ -752,3 +1084,118 @@ func cleanStderr(x []byte) []byte {
 	}
 	return stderr
 }
+
+// splitUsage removes the usage trailer written by the contained
+// process for the run with the given nonce from the end of stderr and
+// returns the remaining stderr along with the decoded usage. If there
+// is no well-formed trailer, it returns stderr unmodified and a nil
+// usage.
+func splitUsage(stderr []byte, nonce string) ([]byte, *sandboxtypes.Usage) {
+	header := usageHeader(nonce)
+	i := bytes.LastIndex(stderr, header)
+	if i == -1 {
+		return stderr, nil
+	}
+	usage := new(sandboxtypes.Usage)
+	if err := json.Unmarshal(stderr[i+len(header):], usage); err != nil {
+		return stderr, nil
+	}
+	return stderr[:i], usage
+}
+
+// Outcomes of a run, as recorded in the kRunOutcome tag. Only
+// outcomeError is the sandbox's fault rather than the program's.
+const (
+	outcomeSuccess   = "success"
+	outcomeNonzero   = "nonzero_exit"
+	outcomeTimeout   = "timeout"
+	outcomeTruncated = "output_too_large"
+	outcomeOOM       = "oom"
+	outcomeError     = "error"
+)
+
+// runOutcome classifies the result of runInContainer.
+func runOutcome(res *sandboxtypes.Response, err error) string {
+	switch {
+	case errors.Is(err, errRunTimeout):
+		return outcomeTimeout
+	case err != nil:
+		return outcomeError
+	case res.OutOfMemory:
+		return outcomeOOM
+	case res.Truncated:
+		return outcomeTruncated
+	case res.ExitCode != 0:
+		return outcomeNonzero
+	}
+	return outcomeSuccess
+}
+
+// goOOMMessage is how the Go runtime reports that an allocation failed.
+var goOOMMessage = []byte("fatal error: runtime: out of memory")
+
+// outOfMemory reports whether the binary run in c, with result res,
+// exceeded its memory limit. Either an allocation failed and the Go
+// runtime said so, or the binary or the whole container was killed
+// and the runtime says it was for using too much memory. Whether the
+// contained process reported usage doesn't matter: the kernel may
+// kill just the binary.
+func outOfMemory(c *Container, res *sandboxtypes.Response) bool {
+	if bytes.Contains(res.Stderr, goOOMMessage) {
+		return true
+	}
+	if res.ExitCode != 128+int(syscall.SIGKILL) || res.Truncated {
+		return false
+	}
+	return sandboxRuntime.OOMKilled(context.Background(), c.name)
+}
+
+// exitCodeBucket returns the kRunExitCode tag value for code. Codes
+// other than the common ones are grouped to keep the tag's
+// cardinality low.
+func exitCodeBucket(code int) string {
+	switch {
+	case code >= 0 && code <= 2:
+		return strconv.Itoa(code)
+	case code < 0:
+		// Runs stopped at the output limit.
+		return "truncated"
+	case code > 128:
+		return "128+"
+	}
+	return "other"
+}
+
+// recordRun records the outcome of a run of a binary of binSize bytes
+// that took d to the metrics service. res is nil if the run didn't
+// complete.
+func recordRun(ctx context.Context, outcome string, res *sandboxtypes.Response, binSize int, d time.Duration) {
+	ctx, err := tag.New(ctx, tag.Upsert(kRunOutcome, outcome))
+	if err != nil {
+		log.Printf("tagging run metrics: %v", err)
+		return
+	}
+	if res != nil {
+		// Ignore error. The only error can be invalid tag key or
+		// value length, which we know are safe.
+		ctx, _ = tag.New(ctx, tag.Upsert(kRunExitCode, exitCodeBucket(res.ExitCode)))
+	}
+	stats.Record(ctx,
+		mRuns.M(1),
+		mRunDuration.M(float64(d)/float64(time.Millisecond)),
+		mRunBinaryBytes.M(int64(binSize)),
+	)
+	if res != nil && res.Usage != nil {
+		recordUsage(ctx, res.Usage)
+	}
+}
+
+// recordUsage records the resource usage of a run to the metrics service.
+func recordUsage(ctx context.Context, u *sandboxtypes.Usage) {
+	stats.Record(ctx,
+		mRunWallTime.M(float64(u.WallTime)/float64(time.Millisecond)),
+		mRunCPUTime.M(float64(u.UserTime+u.SystemTime)/float64(time.Millisecond)),
+		mRunMaxRSS.M(u.MaxRSS),
+		mRunOutputBytes.M(u.StdoutBytes+u.StderrBytes),
+	)
+}
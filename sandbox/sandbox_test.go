@@ -7,16 +7,25 @@ package main
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
 	"testing"
 	"testing/iotest"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"golang.org/x/playground/sandbox/sandboxtypes"
 )
 
 func TestLimitedWriter(t *testing.T) {
@@ -27,7 +36,7 @@ func TestLimitedWriter(t *testing.T) {
 		want          []byte
 		wantN         int64
 		wantRemaining int64
-		err           error
+		wantDropped   int64
 	}{
 		{
 			desc:          "simple",
@@ -52,30 +61,34 @@ func TestLimitedWriter(t *testing.T) {
 			want:          []byte("enough"),
 			wantN:         6,
 			wantRemaining: 0,
-			err:           nil,
 		},
 		{
 			desc:          "writing too much",
 			lw:            &limitedWriter{dst: &bytes.Buffer{}, n: 10},
 			in:            []byte("this is much longer than 10"),
 			want:          []byte("this is mu"),
-			wantN:         10,
-			wantRemaining: -1,
-			err:           errTooMuchOutput,
+			wantN:         27,
+			wantRemaining: 0,
+			wantDropped:   17,
 		},
 	}
 	for _, c := range cases {
 		t.Run(c.desc, func(t *testing.T) {
+			var fullCalls int
+			c.lw.full = func() { fullCalls++ }
 			n, err := io.Copy(c.lw, iotest.OneByteReader(bytes.NewReader(c.in)))
-			if err != c.err {
-				t.Errorf("c.lw.Write(%q) = %d, %q, wanted %d, %q", c.in, n, err, c.wantN, c.err)
-			}
-			if n != c.wantN {
-				t.Errorf("c.lw.Write(%q) = %d, %q, wanted %d, %q", c.in, n, err, c.wantN, c.err)
+			if err != nil || n != c.wantN {
+				t.Errorf("c.lw.Write(%q) = %d, %q, wanted %d, no error", c.in, n, err, c.wantN)
 			}
 			if c.lw.n != c.wantRemaining {
 				t.Errorf("c.lw.n = %d, wanted %d", c.lw.n, c.wantRemaining)
 			}
+			if c.lw.dropped != c.wantDropped {
+				t.Errorf("c.lw.dropped = %d, wanted %d", c.lw.dropped, c.wantDropped)
+			}
+			if wantCalls := min(c.wantDropped, 1); int64(fullCalls) != wantCalls {
+				t.Errorf("c.lw.full called %d times, wanted %d", fullCalls, wantCalls)
+			}
 			if string(c.lw.dst.Bytes()) != string(c.want) {
 				t.Errorf("c.lw.dst.Bytes() = %q, wanted %q", c.lw.dst.Bytes(), c.want)
 			}
@@ -231,23 +244,23 @@ func TestParseDockerContainers(t *testing.T) {
 }
 
 func TestStartContainer_TimeoutNoHang(t *testing.T) {
-	oldExecCommand := execCommand
+	oldRuntime := sandboxRuntime
 	oldStartTimeout := startTimeout
 	defer func() {
-		execCommand = oldExecCommand
+		sandboxRuntime = oldRuntime
 		startTimeout = oldStartTimeout
 	}()
 
 	// Set a short timeout for the test to run quickly
 	startTimeout = 100 * time.Millisecond
 
-	// Mock execCommand to run the test binary itself, behaving as a sleep command.
+	// Mock the runtime's command to run the test binary itself, behaving as a sleep command.
 	// This avoids depending on external "sleep" binary which is not available on Windows.
-	execCommand = func(name string, arg ...string) *exec.Cmd {
+	sandboxRuntime = &dockerRuntime{command: func(name string, arg ...string) *exec.Cmd {
 		cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
 		cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PROCESS=1")
 		return cmd
-	}
+	}}
 
 	// Call startContainer. It should timeout and return an error.
 	// If the hang bug is present, this call will block forever.
@@ -263,7 +276,26 @@ func TestStartContainer_TimeoutNoHang(t *testing.T) {
 
 // TestHelperProcess is a helper process used to simulate long-running/stuck commands.
 func TestHelperProcess(t *testing.T) {
-	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+	switch os.Getenv("GO_WANT_HELPER_PROCESS") {
+	case "1":
+	case "events":
+		// Behave as docker events reporting an OOM, then waiting.
+		fmt.Println("play_run_oom")
+		time.Sleep(time.Hour)
+		os.Exit(0)
+	case "sigkill":
+		// Behave as a binary the kernel killed.
+		if p, err := os.FindProcess(os.Getpid()); err == nil {
+			p.Kill()
+		}
+		time.Sleep(time.Hour)
+	case "contained":
+		// Run a binary that gets killed, and exit as the
+		// contained process does.
+		cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+		cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PROCESS=sigkill")
+		os.Exit(errExitCode(cmd.Run()))
+	default:
 		return
 	}
 	var buf [1]byte
@@ -359,3 +391,493 @@ func TestGetContainerMetrics(t *testing.T) {
 		t.Errorf("metric container_wait_count with tag status=success was not recorded. Rows: %v", rows)
 	}
 }
+
+func TestSplitUsage(t *testing.T) {
+	const nonce = "0123456789abcdef"
+	header := string(usageHeader(nonce))
+	cases := []struct {
+		desc       string
+		in         string
+		wantStderr string
+		wantUsage  *sandboxtypes.Usage
+	}{
+		{
+			desc:       "no trailer",
+			in:         "some output\n",
+			wantStderr: "some output\n",
+		},
+		{
+			desc:       "trailer",
+			in:         "some output\n" + header + `{"wallTime":1000,"userTime":200,"systemTime":30,"maxRSS":4096}` + "\n",
+			wantStderr: "some output\n",
+			wantUsage:  &sandboxtypes.Usage{WallTime: 1000, UserTime: 200, SystemTime: 30, MaxRSS: 4096},
+		},
+		{
+			desc:       "trailer after output without newline",
+			in:         "panic: x" + header + `{"wallTime":5}` + "\n",
+			wantStderr: "panic: x",
+			wantUsage:  &sandboxtypes.Usage{WallTime: 5},
+		},
+		{
+			desc:       "malformed trailer",
+			in:         "out" + header + "{",
+			wantStderr: "out" + header + "{",
+		},
+		{
+			desc:       "trailer forged by the program",
+			in:         "out" + string(usageHeader("guess")) + `{"wallTime":1}` + "\n",
+			wantStderr: "out" + string(usageHeader("guess")) + `{"wallTime":1}` + "\n",
+		},
+		{
+			desc:       "forged trailer before the real one",
+			in:         "out" + string(usageHeader("")) + `{"wallTime":1}` + "\n" + header + `{"wallTime":2}` + "\n",
+			wantStderr: "out" + string(usageHeader("")) + `{"wallTime":1}` + "\n",
+			wantUsage:  &sandboxtypes.Usage{WallTime: 2},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			stderr, usage := splitUsage([]byte(c.in), nonce)
+			if string(stderr) != c.wantStderr {
+				t.Errorf("splitUsage(%q) stderr = %q, wanted %q", c.in, stderr, c.wantStderr)
+			}
+			if diff := cmp.Diff(c.wantUsage, usage); diff != "" {
+				t.Errorf("splitUsage(%q) usage mismatch (-want +got):\n%s", c.in, diff)
+			}
+		})
+	}
+}
+
+// fakeRuntime is a Runtime whose containers are just names.
+type fakeRuntime struct {
+	Runtime  // nil; unimplemented methods panic
+	names    []string
+	killed   []string
+	oomed    []string
+	checkErr error
+	startErr error
+	started  chan string // if non-nil, receives the name of each container started
+}
+
+func (r *fakeRuntime) Start(name string, args []string, stdout, stderr io.Writer) (*exec.Cmd, io.WriteCloser, error) {
+	if r.started != nil {
+		r.started <- name
+	}
+	return nil, nil, r.startErr
+}
+
+func (r *fakeRuntime) Check(ctx context.Context) error { return r.checkErr }
+
+func (r *fakeRuntime) List(ctx context.Context) ([]string, error) { return r.names, nil }
+
+func (r *fakeRuntime) Kill(ctx context.Context, name string) error {
+	r.killed = append(r.killed, name)
+	return nil
+}
+
+func (r *fakeRuntime) OOMKilled(ctx context.Context, name string) bool {
+	return slices.Contains(r.oomed, name)
+}
+
+func TestCheckContainers(t *testing.T) {
+	oldRuntime := sandboxRuntime
+	defer func() { sandboxRuntime = oldRuntime }()
+	sandboxRuntime = &fakeRuntime{names: []string{"play_run_wanted", "play_run_leaked"}}
+	setContainerWanted("play_run_wanted", true)
+	defer setContainerWanted("play_run_wanted", false)
+	defer func() { unwantedSince = map[string]time.Time{} }()
+
+	if err := view.Register(views...); err != nil {
+		if !strings.Contains(err.Error(), "already registered") {
+			t.Fatalf("view.Register: %v", err)
+		}
+	}
+	checkContainers(t.Context(), time.Now())
+
+	for name, want := range map[string]float64{
+		"go-playground/sandbox/container_count":          2,
+		"go-playground/sandbox/unwanted_container_count": 1,
+	} {
+		rows, err := view.RetrieveData(name)
+		if err != nil {
+			t.Fatalf("RetrieveData(%q) failed: %v", name, err)
+		}
+		if len(rows) != 1 {
+			t.Fatalf("RetrieveData(%q) = %d rows, want 1", name, len(rows))
+		}
+		if got := rows[0].Data.(*view.LastValueData).Value; got != want {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestReapContainers(t *testing.T) {
+	oldRuntime := sandboxRuntime
+	defer func() { sandboxRuntime = oldRuntime }()
+	rt := &fakeRuntime{}
+	sandboxRuntime = rt
+	defer func() { unwantedSince = map[string]time.Time{} }()
+
+	const grace = time.Minute
+	start := time.Now()
+	steps := []struct {
+		after      time.Duration
+		unwanted   []string
+		wantKilled []string
+	}{
+		{0, []string{"a", "b"}, nil},
+		{30 * time.Second, []string{"a", "b", "c"}, nil},
+		{40 * time.Second, []string{"b", "c"}, nil}, // a went away by itself
+		{70 * time.Second, []string{"b", "c"}, []string{"b"}},
+		{100 * time.Second, []string{"a", "c"}, []string{"c"}},
+		{150 * time.Second, []string{"a"}, nil},
+		{160 * time.Second, []string{"a"}, []string{"a"}},
+	}
+	for _, s := range steps {
+		rt.killed = nil
+		reapContainers(t.Context(), s.unwanted, start.Add(s.after), grace)
+		if diff := cmp.Diff(s.wantKilled, rt.killed); diff != "" {
+			t.Errorf("after %v: killed mismatch (-want +got):\n%s", s.after, diff)
+		}
+	}
+}
+
+func TestParseRunRequest(t *testing.T) {
+	h := http.Header{"X-Argument": {"-test.v"}}
+	rr, err := parseRunRequest(h, []byte("binary"))
+	if err != nil {
+		t.Fatalf("parseRunRequest(binary) = %v", err)
+	}
+	if want := (&sandboxtypes.RunRequest{Binary: []byte("binary"), Args: []string{"-test.v"}}); !cmp.Equal(rr, want) {
+		t.Errorf("parseRunRequest(binary) = %+v; want %+v", rr, want)
+	}
+
+	framed := func(rr *sandboxtypes.RunRequest) []byte {
+		b, err := rr.MarshalFrames()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	h = http.Header{"Content-Type": {sandboxtypes.FramesContentType}}
+	want := &sandboxtypes.RunRequest{
+		Binary: []byte("binary"),
+		Args:   []string{"-x"},
+		Stdin:  []byte("in"),
+		Files:  map[string][]byte{"testdata/in.txt": []byte("hello")},
+	}
+	rr, err = parseRunRequest(h, framed(want))
+	if err != nil {
+		t.Fatalf("parseRunRequest(framed) = %v", err)
+	}
+	if diff := cmp.Diff(want, rr); diff != "" {
+		t.Errorf("parseRunRequest(framed) mismatch (-want +got):\n%s", diff)
+	}
+
+	for _, tt := range []struct {
+		desc string
+		rr   *sandboxtypes.RunRequest
+	}{
+		{"absolute name", &sandboxtypes.RunRequest{Binary: []byte("b"), Files: map[string][]byte{"/etc/passwd": nil}}},
+		{"escaping name", &sandboxtypes.RunRequest{Binary: []byte("b"), Files: map[string][]byte{"../x": nil}}},
+		{"too much stdin", &sandboxtypes.RunRequest{Binary: []byte("b"), Stdin: make([]byte, policy.MaxDataSize+1)}},
+	} {
+		if _, err := parseRunRequest(h, framed(tt.rr)); err == nil {
+			t.Errorf("%s: parseRunRequest succeeded; want error", tt.desc)
+		}
+	}
+	if _, err := parseRunRequest(h, []byte("not frames")); err == nil {
+		t.Errorf("parseRunRequest(bad frames) succeeded; want error")
+	}
+}
+
+func TestWriteDataFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "run")
+	files := map[string][]byte{
+		"input.txt":             []byte("input"),
+		"testdata/a/golden.txt": []byte("golden"),
+	}
+	if err := writeDataFiles(dir, files); err != nil {
+		t.Fatalf("writeDataFiles: %v", err)
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if err := writeDataFiles(dir, map[string][]byte{"../escape": nil}); err == nil {
+		t.Errorf("writeDataFiles with an escaping name succeeded; want error")
+	}
+}
+
+func TestCollectArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"a.png":     "png",
+		"b/c.csv":   "1,2,3",
+		"d.json":    "{}",
+		"e-too-big": strings.Repeat("x", 100),
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("/etc/hostname", filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	got := collectArtifacts(dir, 10, 20)
+	want := &artifactsTrailer{
+		Artifacts: []sandboxtypes.Artifact{
+			{Name: "a.png", Data: []byte("png")},
+			{Name: "b/c.csv", Data: []byte("1,2,3")},
+			{Name: "d.json", Data: []byte("{}")},
+		},
+		Truncated: true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("collectArtifacts(max size 20) mismatch (-want +got):\n%s", diff)
+	}
+
+	got = collectArtifacts(dir, 1, 1000)
+	want = &artifactsTrailer{
+		Artifacts: []sandboxtypes.Artifact{{Name: "a.png", Data: []byte("png")}},
+		Truncated: true,
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("collectArtifacts(max 1 file) mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestArtifactsFromStderr(t *testing.T) {
+	c := &Container{
+		stderr:    &limitedWriter{dst: &bytes.Buffer{}, n: 1000},
+		artifacts: &limitedWriter{dst: &bytes.Buffer{}, n: 1000},
+	}
+	w := &switchWriter{switchAfter: artifactsHeader("nonce"), dst1: c.stderr, dst2: c.artifacts}
+	// The program can't make its own output be taken for artifacts
+	// without knowing the nonce.
+	forged := fmt.Sprintf("%s{\"artifacts\":[{\"name\":\"fake.txt\",\"data\":\"aGk=\"}]}\n", artifactsHeader("guess"))
+	io.WriteString(w, "program stderr\n"+forged)
+	fmt.Fprintf(w, "%s{\"wallTime\":1}\n", usageHeader("nonce"))
+	fmt.Fprintf(w, "%s{\"artifacts\":[{\"name\":\"a.txt\",\"data\":\"aGk=\"}]}\n", artifactsHeader("nonce"))
+
+	stderr, usage := splitUsage(bytes.TrimSuffix(c.stderr.dst.Bytes(), artifactsHeader("nonce")), "nonce")
+	if want := "program stderr\n" + forged; string(stderr) != want || usage == nil || usage.WallTime != 1 {
+		t.Errorf("stderr, usage = %q, %+v; want %q and a wall time of 1", stderr, usage, want)
+	}
+	arts, truncated := parseArtifacts(c.artifacts)
+	if want := []sandboxtypes.Artifact{{Name: "a.txt", Data: []byte("hi")}}; !cmp.Equal(arts, want) || truncated {
+		t.Errorf("parseArtifacts = %v, %v; want %v, false", arts, truncated, want)
+	}
+}
+
+// readTracker is an io.Reader that records whether it was read.
+type readTracker struct {
+	io.Reader
+	read bool
+}
+
+func (r *readTracker) Read(p []byte) (int, error) {
+	r.read = true
+	return r.Reader.Read(p)
+}
+
+func TestRunHandlerAuth(t *testing.T) {
+	oldVerifier, oldRunSched := runVerifier, runSched
+	defer func() { runVerifier, runSched = oldVerifier, oldRunSched }()
+	key := []byte(strings.Repeat("k", 32))
+	runVerifier = sandboxtypes.NewRunVerifier(key)
+	// Take the only slot, so that a request which tried to take one
+	// would block until its context expired.
+	runSched = newFairScheduler(1, 1)
+	release, err := runSched.acquire(t.Context(), "client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+
+	do := func(body *readTracker, sign func(*http.Request)) int {
+		t.Helper()
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+		req := httptest.NewRequestWithContext(ctx, "POST", "/run", body)
+		sign(req)
+		w := httptest.NewRecorder()
+		runHandler(w, req)
+		if ctx.Err() != nil {
+			t.Fatal("runHandler waited for a run slot")
+		}
+		return w.Code
+	}
+
+	// An unsigned request is rejected without reading its body.
+	body := &readTracker{Reader: strings.NewReader("binary")}
+	if code := do(body, func(*http.Request) {}); code != http.StatusUnauthorized {
+		t.Errorf("unsigned request: status %d; want %d", code, http.StatusUnauthorized)
+	}
+	if body.read {
+		t.Errorf("unsigned request: body was read")
+	}
+
+	// A request signed for a different body is rejected without
+	// taking a slot.
+	body = &readTracker{Reader: strings.NewReader("binary")}
+	code := do(body, func(req *http.Request) {
+		if err := sandboxtypes.SignRun(req, key, []byte("other"), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if code != http.StatusUnauthorized {
+		t.Errorf("request with a bad signature: status %d; want %d", code, http.StatusUnauthorized)
+	}
+	if n := runSched.busy(); n != 1 {
+		t.Errorf("%d run slots busy; want 1", n)
+	}
+}
+
+func TestRunOutcome(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		res  *sandboxtypes.Response
+		err  error
+		want string
+	}{
+		{"success", &sandboxtypes.Response{}, nil, outcomeSuccess},
+		{"exit 1", &sandboxtypes.Response{ExitCode: 1}, nil, outcomeNonzero},
+		{"timeout", nil, errRunTimeout, outcomeTimeout},
+		{"infrastructure", nil, errors.New("broken pipe"), outcomeError},
+		{"truncated", &sandboxtypes.Response{ExitCode: -1, Truncated: true}, nil, outcomeTruncated},
+		{"out of memory", &sandboxtypes.Response{ExitCode: 137, OutOfMemory: true}, nil, outcomeOOM},
+	} {
+		if got := runOutcome(tc.res, tc.err); got != tc.want {
+			t.Errorf("%s: runOutcome = %q; want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRecordRun(t *testing.T) {
+	if err := view.Register(views...); err != nil {
+		if !strings.Contains(err.Error(), "already registered") {
+			t.Fatalf("view.Register: %v", err)
+		}
+	}
+
+	res := &sandboxtypes.Response{ExitCode: 3, Usage: &sandboxtypes.Usage{StdoutBytes: 10}}
+	recordRun(t.Context(), outcomeNonzero, res, 1000, time.Second)
+
+	for _, tc := range []struct {
+		view string
+		key  tag.Key
+		val  string
+	}{
+		{"go-playground/sandbox/run_count", kRunOutcome, outcomeNonzero},
+		{"go-playground/sandbox/run_exit_code_count", kRunExitCode, "other"},
+		{"go-playground/sandbox/run_binary_bytes", kRunOutcome, outcomeNonzero},
+		{"go-playground/sandbox/run_output_bytes_by_outcome", kRunOutcome, outcomeNonzero},
+	} {
+		rows, err := view.RetrieveData(tc.view)
+		if err != nil {
+			t.Fatalf("RetrieveData(%q): %v", tc.view, err)
+		}
+		found := false
+		for _, row := range rows {
+			for _, tg := range row.Tags {
+				found = found || tg.Key == tc.key && tg.Value == tc.val
+			}
+		}
+		if !found {
+			t.Errorf("metric %s with tag %s=%s was not recorded. Rows: %v", tc.view, tc.key.Name(), tc.val, rows)
+		}
+	}
+}
+
+func TestOutOfMemory(t *testing.T) {
+	oldRuntime := sandboxRuntime
+	defer func() { sandboxRuntime = oldRuntime }()
+	sandboxRuntime = &fakeRuntime{oomed: []string{"play_run_oom"}}
+
+	for _, tc := range []struct {
+		name string
+		c    string
+		res  *sandboxtypes.Response
+		want bool
+	}{
+		{"success", "play_run_oom", &sandboxtypes.Response{Usage: &sandboxtypes.Usage{}}, false},
+		{"Go runtime", "play_run_ok", &sandboxtypes.Response{ExitCode: 2, Stderr: []byte("fatal error: runtime: out of memory\n"), Usage: &sandboxtypes.Usage{}}, true},
+		{"container killed", "play_run_oom", &sandboxtypes.Response{ExitCode: 137}, true},
+		{"container killed otherwise", "play_run_ok", &sandboxtypes.Response{ExitCode: 137}, false},
+		{"binary killed", "play_run_oom", &sandboxtypes.Response{ExitCode: 137, Usage: &sandboxtypes.Usage{}}, true},
+		{"binary killed otherwise", "play_run_ok", &sandboxtypes.Response{ExitCode: 137, Usage: &sandboxtypes.Usage{}}, false},
+		{"exit 1", "play_run_oom", &sandboxtypes.Response{ExitCode: 1}, false},
+		{"output limit", "play_run_oom", &sandboxtypes.Response{ExitCode: -1, Truncated: true}, false},
+	} {
+		if got := outOfMemory(&Container{name: tc.c}, tc.res); got != tc.want {
+			t.Errorf("%s: outOfMemory = %v; want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestErrExitCodeSignaled(t *testing.T) {
+	cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+	cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PROCESS=contained")
+	err := cmd.Run()
+	if got, want := errExitCode(err), 128+int(syscall.SIGKILL); got != want {
+		t.Fatalf("contained process exited with %d (%v); want %d", got, err, want)
+	}
+	res := &sandboxtypes.Response{ExitCode: errExitCode(err), Usage: &sandboxtypes.Usage{}}
+	oldRuntime := sandboxRuntime
+	defer func() { sandboxRuntime = oldRuntime }()
+	sandboxRuntime = &fakeRuntime{oomed: []string{"play_run_oom"}}
+	if !outOfMemory(&Container{name: "play_run_oom"}, res) {
+		t.Errorf("outOfMemory = false for a binary killed by the kernel; want true")
+	}
+}
+
+func TestDockerWatchOOM(t *testing.T) {
+	r := &dockerRuntime{command: func(name string, arg ...string) *exec.Cmd {
+		cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess")
+		cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PROCESS=events")
+		return cmd
+	}}
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		r.watchOOM(ctx)
+		close(done)
+	}()
+	// Allow for the helper process being slow to start.
+	deadline := time.Now().Add(10 * time.Second)
+	for !r.OOMKilled(t.Context(), "play_run_oom") {
+		if time.Now().After(deadline) {
+			t.Fatal("OOMKilled(play_run_oom) = false after docker events reported it; want true")
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("watchOOM didn't return when its context was canceled")
+	}
+}
+
+func TestDockerOOMEvents(t *testing.T) {
+	r := &dockerRuntime{}
+	now := time.Now()
+	r.recordOOM("play_run_old", now.Add(-2*time.Minute))
+	r.recordOOM("play_run_oom", now)
+	if !r.OOMKilled(t.Context(), "play_run_oom") {
+		t.Errorf("OOMKilled(play_run_oom) = false after an OOM event; want true")
+	}
+	if _, ok := r.oomed["play_run_old"]; ok {
+		t.Errorf("old OOM event was not forgotten")
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if r.OOMKilled(ctx, "play_run_other") {
+		t.Errorf("OOMKilled(play_run_other) = true without an OOM event; want false")
+	}
+}
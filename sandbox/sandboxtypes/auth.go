@@ -0,0 +1,206 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sandboxtypes
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers that authenticate a request to the sandbox's /run endpoint,
+// or to a build service. The frontend signs each request with a key it
+// shares with the sandbox or build service, so that only it can run
+// binaries there, or build programs.
+const (
+	AuthTimestampHeader = "X-Playground-Timestamp" // Unix time in seconds
+	AuthNonceHeader     = "X-Playground-Nonce"     // random, so identical requests differ
+	AuthSignatureHeader = "X-Playground-Signature" // hex HMAC-SHA256
+)
+
+// MaxAuthSkew is how far from the sandbox's clock the timestamp of a
+// signed request may be.
+const MaxAuthSkew = 30 * time.Second
+
+// minAuthKeyLen is the minimum length in bytes of a shared key.
+const minAuthKeyLen = 32
+
+// nonceLen is the length in bytes of a request's nonce.
+const nonceLen = 16
+
+// LoadAuthKey reads a shared key from the named file. Leading and
+// trailing whitespace is ignored.
+func LoadAuthKey(name string) ([]byte, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	key := bytes.TrimSpace(data)
+	if len(key) < minAuthKeyLen {
+		return nil, fmt.Errorf("auth key in %s is %d bytes; want at least %d", name, len(key), minAuthKeyLen)
+	}
+	return key, nil
+}
+
+// SignRun adds headers to req, a /run request with the given body,
+// authenticating it with key as of time now. The signature covers
+// the digest of body, the X-Argument headers, the time and a nonce.
+func SignRun(req *http.Request, key, body []byte, now time.Time) error {
+	return sign(req, runDomain, key, body, now)
+}
+
+// SignBuild is like SignRun, for a request to a build service. The
+// signature also covers the request's path, so it can't be used for
+// another endpoint.
+func SignBuild(req *http.Request, key, body []byte, now time.Time) error {
+	return sign(req, buildDomain(req), key, body, now)
+}
+
+// runDomain is the domain of /run request signatures, which keeps
+// them from being valid for other requests.
+const runDomain = "playground-run-v1"
+
+// buildDomain returns the domain of the signature of req, a request to
+// a build service.
+func buildDomain(req *http.Request) string {
+	return "playground-build-v1 " + req.URL.Path
+}
+
+func sign(req *http.Request, domain string, key, body []byte, now time.Time) error {
+	var nonce [nonceLen]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(now.Unix(), 10)
+	n := hex.EncodeToString(nonce[:])
+	req.Header.Set(AuthTimestampHeader, ts)
+	req.Header.Set(AuthNonceHeader, n)
+	digest := sha256.Sum256(body)
+	req.Header.Set(AuthSignatureHeader, hex.EncodeToString(requestMAC(key, domain, digest[:], req.Header["X-Argument"], ts, n)))
+	return nil
+}
+
+// requestMAC returns the HMAC of a request in domain whose body has
+// the given SHA-256 digest.
+func requestMAC(key []byte, domain string, digest []byte, args []string, ts, nonce string) []byte {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%x\n", domain, ts, nonce, digest)
+	for _, a := range args {
+		// Length-prefix each argument so the encoding is unambiguous.
+		fmt.Fprintf(mac, "%d:%s\n", len(a), a)
+	}
+	return mac.Sum(nil)
+}
+
+// A RunVerifier checks that /run requests were signed by SignRun
+// with its key, or, if made by NewBuildVerifier, that build service
+// requests were signed by SignBuild. It rejects any request it has
+// seen before.
+//
+// The requests it has seen are only remembered in memory, so replay
+// protection covers a single sandbox process: a request can be
+// replayed, within MaxAuthSkew, to another instance sharing the key,
+// or to this one after it restarts.
+type RunVerifier struct {
+	key    []byte
+	domain func(*http.Request) string
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // decoded signature to timestamp, of recent requests
+	lastSweep time.Time
+}
+
+// NewRunVerifier returns a RunVerifier for requests signed with key.
+func NewRunVerifier(key []byte) *RunVerifier {
+	return &RunVerifier{
+		key:    key,
+		domain: func(*http.Request) string { return runDomain },
+		seen:   map[string]time.Time{},
+	}
+}
+
+// NewBuildVerifier returns a RunVerifier for build service requests
+// signed with key.
+func NewBuildVerifier(key []byte) *RunVerifier {
+	return &RunVerifier{key: key, domain: buildDomain, seen: map[string]time.Time{}}
+}
+
+// CheckHeaders returns an error if req doesn't carry
+// well-formed authentication headers with a timestamp within
+// MaxAuthSkew of now. It doesn't need the body, so it can reject a
+// request before the body is read; VerifyDigest checks the signature.
+func (v *RunVerifier) CheckHeaders(req *http.Request, now time.Time) error {
+	ts := req.Header.Get(AuthTimestampHeader)
+	nonce := req.Header.Get(AuthNonceHeader)
+	sigHex := req.Header.Get(AuthSignatureHeader)
+	if ts == "" || nonce == "" || sigHex == "" {
+		return errors.New("request is not signed")
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad timestamp %q", ts)
+	}
+	t := time.Unix(sec, 0)
+	if d := now.Sub(t); d > MaxAuthSkew || d < -MaxAuthSkew {
+		return fmt.Errorf("timestamp %v is too far from now (%v)", t.UTC(), now.UTC())
+	}
+	if n, err := hex.DecodeString(nonce); err != nil || len(n) != nonceLen {
+		return errors.New("bad nonce")
+	}
+	if sig, err := hex.DecodeString(sigHex); err != nil || len(sig) != sha256.Size {
+		return errors.New("bad signature")
+	}
+	return nil
+}
+
+// VerifyDigest returns an error if req, whose body has the given
+// SHA-256 digest, fails CheckHeaders, isn't correctly
+// signed, or is a replay of an earlier request.
+func (v *RunVerifier) VerifyDigest(req *http.Request, digest []byte, now time.Time) error {
+	if err := v.CheckHeaders(req, now); err != nil {
+		return err
+	}
+	ts := req.Header.Get(AuthTimestampHeader)
+	nonce := req.Header.Get(AuthNonceHeader)
+	sigHex := req.Header.Get(AuthSignatureHeader)
+	sig, _ := hex.DecodeString(sigHex)
+	if !hmac.Equal(sig, requestMAC(v.key, v.domain(req), digest, req.Header["X-Argument"], ts, nonce)) {
+		return errors.New("bad signature")
+	}
+	sec, _ := strconv.ParseInt(ts, 10, 64)
+	t := time.Unix(sec, 0)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if now.Sub(v.lastSweep) > MaxAuthSkew {
+		// Forget requests that are too old to be accepted again anyway.
+		for s, t := range v.seen {
+			if now.Sub(t) > MaxAuthSkew {
+				delete(v.seen, s)
+			}
+		}
+		v.lastSweep = now
+	}
+	if _, ok := v.seen[string(sig)]; ok {
+		return errors.New("replayed request")
+	}
+	v.seen[string(sig)] = t
+	return nil
+}
+
+// Verify is like VerifyDigest, for a request with the given body.
+func (v *RunVerifier) Verify(req *http.Request, body []byte, now time.Time) error {
+	digest := sha256.Sum256(body)
+	return v.VerifyDigest(req, digest[:], now)
+}
@@ -0,0 +1,168 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sandboxtypes
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunVerifier(t *testing.T) {
+	key := []byte(strings.Repeat("k", minAuthKeyLen))
+	bin := []byte("\x7fELF binary")
+	now := time.Unix(1_800_000_000, 0)
+
+	newReq := func(signKey []byte, signedAt time.Time, args ...string) *http.Request {
+		req, err := http.NewRequest("POST", "http://sandbox/run", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, a := range args {
+			req.Header.Add("X-Argument", a)
+		}
+		if signKey != nil {
+			if err := SignRun(req, signKey, bin, signedAt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return req
+	}
+
+	withHeader := func(req *http.Request, name, value string) *http.Request {
+		req.Header.Set(name, value)
+		return req
+	}
+
+	for _, tt := range []struct {
+		desc    string
+		req     *http.Request
+		bin     []byte
+		wantErr string
+		headers bool // whether CheckHeaders alone rejects req
+	}{
+		{desc: "valid", req: newReq(key, now, "-test.v"), bin: bin},
+		{desc: "valid with clock skew", req: newReq(key, now.Add(-MaxAuthSkew)), bin: bin},
+		{desc: "unsigned", req: newReq(nil, now), bin: bin, wantErr: "not signed", headers: true},
+		{desc: "wrong key", req: newReq([]byte(strings.Repeat("x", minAuthKeyLen)), now), bin: bin, wantErr: "bad signature"},
+		{desc: "different binary", req: newReq(key, now), bin: []byte("other"), wantErr: "bad signature"},
+		{desc: "too old", req: newReq(key, now.Add(-2*MaxAuthSkew)), bin: bin, wantErr: "too far", headers: true},
+		{desc: "too new", req: newReq(key, now.Add(2*MaxAuthSkew)), bin: bin, wantErr: "too far", headers: true},
+		{desc: "short nonce", req: withHeader(newReq(key, now), AuthNonceHeader, "abcd"), bin: bin, wantErr: "bad nonce", headers: true},
+		{desc: "short signature", req: withHeader(newReq(key, now), AuthSignatureHeader, "abcd"), bin: bin, wantErr: "bad signature", headers: true},
+		{desc: "non-hex signature", req: withHeader(newReq(key, now), AuthSignatureHeader, strings.Repeat("z", 64)), bin: bin, wantErr: "bad signature", headers: true},
+		{
+			desc: "changed args",
+			req: func() *http.Request {
+				req := newReq(key, now, "-test.run=A")
+				req.Header["X-Argument"] = []string{"-test.run=B"}
+				return req
+			}(),
+			bin:     bin,
+			wantErr: "bad signature",
+		},
+	} {
+		t.Run(tt.desc, func(t *testing.T) {
+			v := NewRunVerifier(key)
+			if err := v.CheckHeaders(tt.req, now); (err != nil) != tt.headers {
+				t.Errorf("CheckHeaders() = %v; want error: %t", err, tt.headers)
+			}
+			err := v.Verify(tt.req, tt.bin, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Verify() = %v; want nil", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Verify() = %v; want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunVerifierReplay(t *testing.T) {
+	key := []byte(strings.Repeat("k", minAuthKeyLen))
+	bin := []byte("binary")
+	now := time.Unix(1_800_000_000, 0)
+	v := NewRunVerifier(key)
+
+	req, _ := http.NewRequest("POST", "http://sandbox/run", nil)
+	if err := SignRun(req, key, bin, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(req, bin, now); err != nil {
+		t.Fatalf("first Verify() = %v; want nil", err)
+	}
+	if err := v.Verify(req, bin, now.Add(time.Second)); err == nil || !strings.Contains(err.Error(), "replayed") {
+		t.Errorf("second Verify() = %v; want replay error", err)
+	}
+	// Hex is case-insensitive, so the same signature spelled
+	// differently is still a replay.
+	req.Header.Set(AuthSignatureHeader, strings.ToUpper(req.Header.Get(AuthSignatureHeader)))
+	if err := v.Verify(req, bin, now.Add(time.Second)); err == nil || !strings.Contains(err.Error(), "replayed") {
+		t.Errorf("Verify() with an upper-case signature = %v; want replay error", err)
+	}
+
+	// An identical request signed separately has a new nonce.
+	req2, _ := http.NewRequest("POST", "http://sandbox/run", nil)
+	if err := SignRun(req2, key, bin, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(req2, bin, now.Add(time.Second)); err != nil {
+		t.Errorf("Verify() of a new identical request = %v; want nil", err)
+	}
+
+	// Once requests are too old to be accepted, they're forgotten.
+	later := now.Add(2 * MaxAuthSkew)
+	req3, _ := http.NewRequest("POST", "http://sandbox/run", nil)
+	if err := SignRun(req3, key, bin, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Verify(req3, bin, later); err != nil {
+		t.Errorf("Verify() of a later request = %v; want nil", err)
+	}
+	if len(v.seen) != 1 {
+		t.Errorf("after MaxAuthSkew, %d requests remembered; want 1", len(v.seen))
+	}
+}
+
+func TestBuildVerifier(t *testing.T) {
+	key := []byte(strings.Repeat("k", minAuthKeyLen))
+	body := []byte(`{"body":"package main"}`)
+	now := time.Unix(1_800_000_000, 0)
+
+	newReq := func(path string) *http.Request {
+		req, err := http.NewRequest("POST", "http://builder"+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	req := newReq("/build")
+	if err := SignBuild(req, key, body, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewBuildVerifier(key).Verify(req, body, now); err != nil {
+		t.Errorf("Verify() of a signed build request = %v; want nil", err)
+	}
+
+	// A build request's signature isn't valid for /run requests, or
+	// for another build service endpoint.
+	if err := NewRunVerifier(key).Verify(req, body, now); err == nil {
+		t.Errorf("run verifier accepted a build request")
+	}
+	vet := newReq("/vet")
+	vet.Header = req.Header.Clone()
+	if err := NewBuildVerifier(key).Verify(vet, body, now); err == nil {
+		t.Errorf("build verifier accepted a /build signature for /vet")
+	}
+	run := newReq("/run")
+	if err := SignRun(run, key, body, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewBuildVerifier(key).Verify(run, body, now); err == nil {
+		t.Errorf("build verifier accepted a run request")
+	}
+}
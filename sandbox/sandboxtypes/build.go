@@ -0,0 +1,107 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sandboxtypes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A BuildRequest is the JSON body of a request to a build service's
+// /build endpoint.
+type BuildRequest struct {
+	Body    string `json:"body"`              // Go source, or a txtar archive of files
+	WithVet bool   `json:"withVet,omitempty"` // also run go vet
+}
+
+// A VetResult is a build service's JSON reply to a BuildRequest sent
+// to its /vet endpoint, which runs go vet on a single Go file.
+type VetResult struct {
+	Errors string `json:"errors,omitempty"` // output of go vet, if any
+}
+
+// A BuildResult is a build service's reply to a BuildRequest. It's
+// sent as frames: a FrameResult holding the JSON fields, then, if the
+// build succeeded, a FrameBinary and a FrameFile for each data file.
+type BuildResult struct {
+	// Errors, if non-empty, are user-visible build errors, and
+	// there's no binary.
+	Errors string `json:"errors,omitempty"`
+	// VetErrors is the output of go vet, if requested.
+	VetErrors string `json:"vetErrors,omitempty"`
+	// TestParam, if set, is the argument to run the binary with,
+	// as it's a test.
+	TestParam string `json:"testParam,omitempty"`
+	// Dir is the directory the program was built in, which appears
+	// in file names in the binary's stack traces.
+	Dir string `json:"dir"`
+
+	Binary []byte            `json:"-"`
+	Files  map[string][]byte `json:"-"` // data files, keyed by slash-separated path
+}
+
+// MarshalFrames returns br encoded as frames.
+func (br *BuildResult) MarshalFrames() ([]byte, error) {
+	j, err := json.Marshal(br)
+	if err != nil {
+		return nil, err
+	}
+	var buf frameBuffer
+	buf.frame(FrameResult, j)
+	if br.Binary != nil {
+		buf.frame(FrameBinary, br.Binary)
+	}
+	for name, data := range br.Files {
+		buf.named(FrameFile, name, data)
+	}
+	return buf.Bytes(), buf.err
+}
+
+// ReadBuildResultFrames reads a BuildResult encoded by MarshalFrames
+// from r. Frames of unknown types are ignored.
+func ReadBuildResultFrames(r io.Reader) (*BuildResult, error) {
+	var (
+		br     *BuildResult
+		binary []byte
+		files  map[string][]byte
+	)
+	for {
+		t, p, err := ReadFrame(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case FrameResult:
+			br = new(BuildResult)
+			if err := json.Unmarshal(p, br); err != nil {
+				return nil, fmt.Errorf("decoding build result: %v", err)
+			}
+		case FrameBinary:
+			binary = p
+		case FrameFile:
+			name, data, err := splitNamed(p)
+			if err != nil {
+				return nil, err
+			}
+			if files == nil {
+				files = make(map[string][]byte)
+			}
+			files[name] = data
+		}
+	}
+	if br == nil {
+		return nil, errors.New("build response has no result")
+	}
+	if br.Errors == "" && binary == nil {
+		return nil, errors.New("build response has neither errors nor a binary")
+	}
+	br.Binary, br.Files = binary, files
+	return br, nil
+}
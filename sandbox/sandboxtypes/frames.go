@@ -0,0 +1,292 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sandboxtypes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"time"
+)
+
+// FramesContentType is the media type of /run requests and responses
+// in the framed protocol. Frontends that accept it send it in the
+// Accept header; others get a JSON Response.
+//
+// A framed body is a sequence of frames, each a FrameType byte, a
+// big-endian uint32 payload length and the payload. Strings within a
+// payload are prefixed by their length as a big-endian uint16.
+const FramesContentType = "application/x-playground-frames; version=1"
+
+// IsFramesContentType reports whether contentType, from a
+// Content-Type or Accept header, is FramesContentType.
+func IsFramesContentType(contentType string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "application/x-playground-frames" && params["version"] == "1"
+}
+
+// AcceptsFrames reports whether an Accept header lists
+// FramesContentType.
+func AcceptsFrames(accept string) bool {
+	for _, t := range strings.Split(accept, ",") {
+		if IsFramesContentType(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// A FrameType identifies the contents of a frame.
+type FrameType byte
+
+// Frames of a request.
+const (
+	FrameMeta   FrameType = 1 // JSON runMeta
+	FrameBinary FrameType = 2 // the binary to run
+	FrameStdin  FrameType = 3 // the binary's standard input
+	FrameFile   FrameType = 4 // a data file: its name, then its contents
+)
+
+// Frames of a response.
+const (
+	FrameStdout   FrameType = 16 // part of the binary's standard output
+	FrameStderr   FrameType = 17 // part of the binary's standard error
+	FrameArtifact FrameType = 18 // an Artifact: its name, then its contents
+	FrameResult   FrameType = 19 // JSON Response, without the above
+)
+
+// maxFrameSize is the largest frame payload ReadFrame accepts.
+const maxFrameSize = 1 << 30
+
+// WriteFrame writes a frame of type t with the given payload to w.
+func WriteFrame(w io.Writer, t FrameType, payload []byte) error {
+	if len(payload) > maxFrameSize {
+		return fmt.Errorf("frame of %d bytes is too large", len(payload))
+	}
+	var hdr [5]byte
+	hdr[0] = byte(t)
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(payload)))
+	if _, err := w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// ReadFrame reads a frame from r. It returns io.EOF if r has no more
+// frames.
+func ReadFrame(r io.Reader) (FrameType, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			err = errors.New("truncated frame header")
+		}
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > maxFrameSize {
+		return 0, nil, fmt.Errorf("frame of %d bytes is too large", n)
+	}
+	// Read rather than allocate n bytes up front, in case r is short.
+	payload, err := io.ReadAll(io.LimitReader(r, int64(n)))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(payload) != int(n) {
+		return 0, nil, errors.New("truncated frame")
+	}
+	return FrameType(hdr[0]), payload, nil
+}
+
+// frameBuffer accumulates frames, remembering the first error.
+type frameBuffer struct {
+	bytes.Buffer
+	err error
+}
+
+func (b *frameBuffer) frame(t FrameType, payload []byte) {
+	if b.err == nil {
+		b.err = WriteFrame(&b.Buffer, t, payload)
+	}
+}
+
+// named writes a frame of type t holding a named file.
+func (b *frameBuffer) named(t FrameType, name string, data []byte) {
+	if len(name) > 0xffff {
+		if b.err == nil {
+			b.err = fmt.Errorf("name %.20q... is too long", name)
+		}
+		return
+	}
+	p := make([]byte, 2, 2+len(name)+len(data))
+	binary.BigEndian.PutUint16(p, uint16(len(name)))
+	p = append(p, name...)
+	b.frame(t, append(p, data...))
+}
+
+// splitNamed splits the payload of a frame holding a named file.
+func splitNamed(p []byte) (name string, data []byte, err error) {
+	if len(p) < 2 || len(p)-2 < int(binary.BigEndian.Uint16(p)) {
+		return "", nil, errors.New("malformed named frame")
+	}
+	n := 2 + int(binary.BigEndian.Uint16(p))
+	return string(p[2:n]), p[n:], nil
+}
+
+// A RunRequest is a request to the sandbox to run a binary.
+type RunRequest struct {
+	Binary []byte
+	Args   []string          // arguments, not including the program name
+	Env    []string          // extra environment variables, as "key=value"
+	Stdin  []byte            // standard input
+	Files  map[string][]byte // data files, keyed by slash-separated path
+
+	// MaxRunTime and MaxOutputSize, if positive, lower the
+	// sandbox's limits for this run.
+	MaxRunTime    time.Duration
+	MaxOutputSize int64
+}
+
+// runMeta is the JSON payload of a FrameMeta.
+type runMeta struct {
+	Args          []string      `json:"args,omitempty"`
+	Env           []string      `json:"env,omitempty"`
+	MaxRunTime    time.Duration `json:"maxRunTime,omitempty"`
+	MaxOutputSize int64         `json:"maxOutputSize,omitempty"`
+}
+
+// MarshalFrames returns req encoded as frames.
+func (req *RunRequest) MarshalFrames() ([]byte, error) {
+	var buf frameBuffer
+	meta, err := json.Marshal(runMeta{
+		Args:          req.Args,
+		Env:           req.Env,
+		MaxRunTime:    req.MaxRunTime,
+		MaxOutputSize: req.MaxOutputSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	buf.frame(FrameMeta, meta)
+	if len(req.Stdin) > 0 {
+		buf.frame(FrameStdin, req.Stdin)
+	}
+	for name, data := range req.Files {
+		buf.named(FrameFile, name, data)
+	}
+	buf.frame(FrameBinary, req.Binary)
+	return buf.Bytes(), buf.err
+}
+
+// UnmarshalRunRequest decodes a RunRequest encoded by MarshalFrames.
+// Frames of unknown types are ignored.
+func UnmarshalRunRequest(data []byte) (*RunRequest, error) {
+	req := new(RunRequest)
+	r := bytes.NewReader(data)
+	sawMeta, sawBinary := false, false
+	for {
+		t, p, err := ReadFrame(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case FrameMeta:
+			var m runMeta
+			if err := json.Unmarshal(p, &m); err != nil {
+				return nil, fmt.Errorf("decoding request metadata: %v", err)
+			}
+			req.Args, req.Env, req.MaxRunTime, req.MaxOutputSize = m.Args, m.Env, m.MaxRunTime, m.MaxOutputSize
+			sawMeta = true
+		case FrameBinary:
+			req.Binary, sawBinary = p, true
+		case FrameStdin:
+			req.Stdin = append(req.Stdin, p...)
+		case FrameFile:
+			name, data, err := splitNamed(p)
+			if err != nil {
+				return nil, err
+			}
+			if req.Files == nil {
+				req.Files = make(map[string][]byte)
+			}
+			req.Files[name] = data
+		}
+	}
+	if !sawMeta || !sawBinary {
+		return nil, errors.New("request is missing metadata or binary")
+	}
+	return req, nil
+}
+
+// MarshalFrames returns res encoded as frames.
+func (res *Response) MarshalFrames() ([]byte, error) {
+	var buf frameBuffer
+	if len(res.Stdout) > 0 {
+		buf.frame(FrameStdout, res.Stdout)
+	}
+	if len(res.Stderr) > 0 {
+		buf.frame(FrameStderr, res.Stderr)
+	}
+	for _, a := range res.Artifacts {
+		buf.named(FrameArtifact, a.Name, a.Data)
+	}
+	result := *res
+	result.Stdout, result.Stderr, result.Artifacts = nil, nil, nil
+	j, err := json.Marshal(&result)
+	if err != nil {
+		return nil, err
+	}
+	buf.frame(FrameResult, j)
+	return buf.Bytes(), buf.err
+}
+
+// ReadResponseFrames reads a Response encoded by MarshalFrames from r.
+// Frames of unknown types are ignored.
+func ReadResponseFrames(r io.Reader) (*Response, error) {
+	var (
+		stdout, stderr []byte
+		artifacts      []Artifact
+		res            *Response
+	)
+	for {
+		t, p, err := ReadFrame(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t {
+		case FrameStdout:
+			stdout = append(stdout, p...)
+		case FrameStderr:
+			stderr = append(stderr, p...)
+		case FrameArtifact:
+			name, data, err := splitNamed(p)
+			if err != nil {
+				return nil, err
+			}
+			artifacts = append(artifacts, Artifact{Name: name, Data: data})
+		case FrameResult:
+			res = new(Response)
+			if err := json.Unmarshal(p, res); err != nil {
+				return nil, fmt.Errorf("decoding result: %v", err)
+			}
+		}
+	}
+	if res == nil {
+		return nil, errors.New("response has no result")
+	}
+	res.Stdout, res.Stderr, res.Artifacts = stdout, stderr, artifacts
+	return res, nil
+}
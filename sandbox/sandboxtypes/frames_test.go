@@ -0,0 +1,138 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sandboxtypes
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunRequestFrames(t *testing.T) {
+	want := &RunRequest{
+		Binary:        []byte("\x7fELF binary"),
+		Args:          []string{"-test.v", "-test.run=X"},
+		Env:           []string{"GODEBUG=x=1"},
+		Stdin:         []byte("input\n"),
+		Files:         map[string][]byte{"testdata/a.txt": []byte("a"), "b": {}},
+		MaxRunTime:    time.Second,
+		MaxOutputSize: 1 << 10,
+	}
+	data, err := want.MarshalFrames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := UnmarshalRunRequest(data)
+	if err != nil {
+		t.Fatalf("UnmarshalRunRequest: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("UnmarshalRunRequest = %+v; want %+v", got, want)
+	}
+
+	for _, tt := range []struct {
+		desc string
+		data []byte
+	}{
+		{"truncated", data[:len(data)-1]},
+		{"truncated header", data[:3]},
+		{"no binary", func() []byte {
+			var buf bytes.Buffer
+			WriteFrame(&buf, FrameMeta, []byte("{}"))
+			return buf.Bytes()
+		}()},
+		{"bad file", func() []byte {
+			var buf bytes.Buffer
+			WriteFrame(&buf, FrameMeta, []byte("{}"))
+			WriteFrame(&buf, FrameFile, []byte{0, 9, 'x'})
+			WriteFrame(&buf, FrameBinary, []byte("bin"))
+			return buf.Bytes()
+		}()},
+	} {
+		if _, err := UnmarshalRunRequest(tt.data); err == nil {
+			t.Errorf("%s: UnmarshalRunRequest succeeded; want error", tt.desc)
+		}
+	}
+}
+
+func TestResponseFrames(t *testing.T) {
+	want := &Response{
+		ExitCode:  2,
+		Stdout:    []byte("out"),
+		Stderr:    []byte("err"),
+		Truncated: true,
+		Usage:     &Usage{WallTime: time.Second, MaxRSS: 1 << 20},
+		Artifacts: []Artifact{{Name: "a.png", Data: []byte("png")}, {Name: "b/c.csv", Data: []byte("1,2")}},
+	}
+	data, err := want.MarshalFrames()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Unknown frames are skipped, so the protocol can grow.
+	var buf bytes.Buffer
+	WriteFrame(&buf, 99, []byte("future"))
+	buf.Write(data)
+	got, err := ReadResponseFrames(&buf)
+	if err != nil {
+		t.Fatalf("ReadResponseFrames: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadResponseFrames = %+v; want %+v", got, want)
+	}
+
+	if _, err := ReadResponseFrames(bytes.NewReader(nil)); err == nil || !strings.Contains(err.Error(), "no result") {
+		t.Errorf("ReadResponseFrames(empty) = %v; want no result error", err)
+	}
+}
+
+func TestAcceptsFrames(t *testing.T) {
+	for _, tt := range []struct {
+		accept string
+		want   bool
+	}{
+		{FramesContentType, true},
+		{"application/json, " + FramesContentType, true},
+		{"application/x-playground-frames;version=1", true},
+		{"application/x-playground-frames; version=2", false},
+		{"application/json", false},
+		{"", false},
+	} {
+		if got := AcceptsFrames(tt.accept); got != tt.want {
+			t.Errorf("AcceptsFrames(%q) = %v; want %v", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestBuildResultFrames(t *testing.T) {
+	for _, want := range []*BuildResult{
+		{Errors: "prog.go:3:1: syntax error", Dir: "/tmp/sandbox1"},
+		{
+			VetErrors: "prog.go:5:2: fmt.Printf call has arguments but no formatting directives",
+			TestParam: "-test.v",
+			Dir:       "/tmp/sandbox2",
+			Binary:    []byte("\x7fELF"),
+			Files:     map[string][]byte{"testdata/golden": []byte("x")},
+		},
+	} {
+		data, err := want.MarshalFrames()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadBuildResultFrames(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("ReadBuildResultFrames: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ReadBuildResultFrames = %+v; want %+v", got, want)
+		}
+	}
+
+	data, _ := (&BuildResult{Dir: "/tmp"}).MarshalFrames()
+	if _, err := ReadBuildResultFrames(bytes.NewReader(data)); err == nil {
+		t.Errorf("ReadBuildResultFrames of a result without errors or binary succeeded; want error")
+	}
+}
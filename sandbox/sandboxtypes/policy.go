@@ -0,0 +1,212 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sandboxtypes
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Policy is the set of resource limits enforced by the playground
+// frontend and the sandbox backend. Each binary only enforces the
+// limits that apply to it, but both load the same Policy so that a
+// deployment can be configured in one place.
+type Policy struct {
+	// MaxBuildTime is the time allowed for 'go build' to download
+	// 3rd-party modules and compile.
+	MaxBuildTime time.Duration `json:"maxBuildTime"`
+	// MaxRunTime is the time allowed for a binary to run.
+	MaxRunTime time.Duration `json:"maxRunTime"`
+	// MaxRuns is the most times a /compile request may ask for its
+	// program to be run, to check that its output doesn't vary.
+	MaxRuns int `json:"maxRuns"`
+
+	// MaxRequestSize is the maximum size in bytes of a /compile,
+	// /fmt or /vet request body.
+	MaxRequestSize int64 `json:"maxRequestSize"`
+	// MaxSnippetSize is the maximum size in bytes of a shared snippet.
+	MaxSnippetSize int64 `json:"maxSnippetSize"`
+	// MaxFiles, MaxFileNameLen and MaxFileDepth limit the files
+	// in a txtar archive.
+	MaxFiles       int `json:"maxFiles"`
+	MaxFileNameLen int `json:"maxFileNameLen"`
+	MaxFileDepth   int `json:"maxFileDepth"`
+
+	// MaxBinarySize is the maximum size in bytes of a built binary.
+	MaxBinarySize int64 `json:"maxBinarySize"`
+	// MaxDataSize is the maximum total size in bytes of the data
+	// files, such as testdata, sent along with a binary for it to read.
+	MaxDataSize int64 `json:"maxDataSize"`
+	// MaxOutputSize is the maximum number of bytes kept from each
+	// of a binary's stdout and stderr.
+	MaxOutputSize int64 `json:"maxOutputSize"`
+	// MaxArtifacts and MaxArtifactsSize limit the number and total
+	// size in bytes of the files a binary writes to its output
+	// directory that are returned with its output.
+	MaxArtifacts     int   `json:"maxArtifacts"`
+	MaxArtifactsSize int64 `json:"maxArtifactsSize"`
+	// MemoryLimit is the memory limit in bytes of a sandbox container.
+	MemoryLimit int64 `json:"memoryLimit"`
+}
+
+// DefaultPolicy returns the limits used by play.golang.org.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxBuildTime:     10 * time.Second,
+		MaxRunTime:       5 * time.Second,
+		MaxRuns:          5,
+		MaxRequestSize:   4 << 20,
+		MaxSnippetSize:   64 << 10,
+		MaxFiles:         20,
+		MaxFileNameLen:   200,
+		MaxFileDepth:     10,
+		MaxBinarySize:    100 << 20,
+		MaxDataSize:      1 << 20,
+		MaxOutputSize:    100 << 20,
+		MaxArtifacts:     10,
+		MaxArtifactsSize: 512 << 10,
+		MemoryLimit:      100 << 20,
+	}
+}
+
+// A policyFlag describes the flag for one limit in a Policy.
+type policyFlag struct {
+	name  string
+	usage string
+	field func(p *Policy) any // returns a *time.Duration, *int or *int64
+}
+
+// policyFlags describes the flags registered by RegisterFlags, one for
+// each field of Policy. Validate checks the same fields.
+var policyFlags = []policyFlag{
+	{"max-build-time", "time allowed to build a program", func(p *Policy) any { return &p.MaxBuildTime }},
+	{"max-run-time", "time allowed to run a program", func(p *Policy) any { return &p.MaxRunTime }},
+	{"max-runs", "maximum number of times a /compile request may run its program", func(p *Policy) any { return &p.MaxRuns }},
+	{"max-request-size", "maximum size in bytes of a /compile, /fmt or /vet request body", func(p *Policy) any { return &p.MaxRequestSize }},
+	{"max-snippet-size", "maximum size in bytes of a shared snippet", func(p *Policy) any { return &p.MaxSnippetSize }},
+	{"max-files", "maximum number of files in a txtar archive", func(p *Policy) any { return &p.MaxFiles }},
+	{"max-file-name-len", "maximum length of a file name in a txtar archive", func(p *Policy) any { return &p.MaxFileNameLen }},
+	{"max-file-depth", "maximum directory depth of a file in a txtar archive", func(p *Policy) any { return &p.MaxFileDepth }},
+	{"max-binary-size", "maximum size in bytes of a built binary", func(p *Policy) any { return &p.MaxBinarySize }},
+	{"max-data-size", "maximum total size in bytes of the data files sent with a binary", func(p *Policy) any { return &p.MaxDataSize }},
+	{"max-output-size", "maximum bytes of stdout and of stderr kept from a program", func(p *Policy) any { return &p.MaxOutputSize }},
+	{"max-artifacts", "maximum number of output files returned from a program", func(p *Policy) any { return &p.MaxArtifacts }},
+	{"max-artifacts-size", "maximum total size in bytes of the output files returned from a program", func(p *Policy) any { return &p.MaxArtifactsSize }},
+	{"memory-limit", "memory limit in bytes of a sandbox container", func(p *Policy) any { return &p.MemoryLimit }},
+}
+
+// RegisterFlags registers a flag in fs for each limit in p, using
+// p's current values as the defaults.
+func (p *Policy) RegisterFlags(fs *flag.FlagSet) {
+	for _, f := range policyFlags {
+		switch v := f.field(p).(type) {
+		case *time.Duration:
+			fs.DurationVar(v, f.name, *v, f.usage)
+		case *int:
+			fs.IntVar(v, f.name, *v, f.usage)
+		case *int64:
+			fs.Int64Var(v, f.name, *v, f.usage)
+		}
+	}
+}
+
+// LoadFile applies the JSON policy in the named file on top of p.
+// Durations are written as strings, such as "5s". Limits that were
+// explicitly set by flags registered in fs with RegisterFlags take
+// precedence over the file. The result should be checked with
+// Validate.
+func (p *Policy) LoadFile(name string, fs *flag.FlagSet) error {
+	set := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = f.Value.String() })
+
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, p); err != nil {
+		return fmt.Errorf("parsing policy %s: %w", name, err)
+	}
+	for _, f := range policyFlags {
+		if v, ok := set[f.name]; ok {
+			if err := fs.Set(f.name, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Validate reports an error if any limit in p is not positive.
+func (p *Policy) Validate() error {
+	for _, f := range policyFlags {
+		positive := false
+		switch v := f.field(p).(type) {
+		case *time.Duration:
+			positive = *v > 0
+		case *int:
+			positive = *v > 0
+		case *int64:
+			positive = *v > 0
+		}
+		if !positive {
+			return fmt.Errorf("policy limit %s must be positive", f.name)
+		}
+	}
+	return nil
+}
+
+// duration is a time.Duration that is encoded in JSON as a string.
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// policyJSON is the JSON form of Policy, with durations as strings.
+type policyJSON struct {
+	*plainPolicy
+	MaxBuildTime duration `json:"maxBuildTime"`
+	MaxRunTime   duration `json:"maxRunTime"`
+}
+
+type plainPolicy Policy
+
+func (p Policy) MarshalJSON() ([]byte, error) {
+	return json.Marshal(policyJSON{
+		plainPolicy:  (*plainPolicy)(&p),
+		MaxBuildTime: duration(p.MaxBuildTime),
+		MaxRunTime:   duration(p.MaxRunTime),
+	})
+}
+
+func (p *Policy) UnmarshalJSON(b []byte) error {
+	v := policyJSON{
+		plainPolicy:  (*plainPolicy)(p),
+		MaxBuildTime: duration(p.MaxBuildTime),
+		MaxRunTime:   duration(p.MaxRunTime),
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	p.MaxBuildTime = time.Duration(v.MaxBuildTime)
+	p.MaxRunTime = time.Duration(v.MaxRunTime)
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sandboxtypes
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPolicyJSON(t *testing.T) {
+	p := DefaultPolicy()
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("json.Marshal(%+v) = _, %v", p, err)
+	}
+	var got Policy
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatalf("json.Unmarshal(%s) = %v", b, err)
+	}
+	if got != p {
+		t.Errorf("round trip through %s = %+v, want %+v", b, got, p)
+	}
+}
+
+func TestPolicyLoadFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "policy.json")
+	if err := os.WriteFile(name, []byte(`{"maxRunTime": "10s", "maxFiles": 50, "memoryLimit": 1024}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := DefaultPolicy()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	p.RegisterFlags(fs)
+	if err := fs.Parse([]string{"-max-files=7"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.LoadFile(name, fs); err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+
+	want := DefaultPolicy()
+	want.MaxRunTime = 10 * time.Second
+	want.MaxFiles = 7 // the flag wins over the file
+	want.MemoryLimit = 1024
+	if p != want {
+		t.Errorf("LoadFile = %+v, want %+v", p, want)
+	}
+}
+
+func TestPolicyLoadFileInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name, data string
+	}{
+		{"numeric duration", `{"maxRunTime": 5000000000}`},
+		{"bad duration", `{"maxBuildTime": "forever"}`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(t.TempDir(), "policy.json")
+			if err := os.WriteFile(name, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			p := DefaultPolicy()
+			if err := p.LoadFile(name, flag.NewFlagSet("test", flag.ContinueOnError)); err == nil {
+				t.Errorf("LoadFile(%s) = nil, want error", tt.data)
+			}
+		})
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		args []string
+		file string
+	}{
+		{"zero limit in file", nil, `{"maxOutputSize": 0}`},
+		{"zero flag", []string{"-max-runs=0"}, ""},
+		{"negative flag", []string{"-max-run-time=-1s"}, ""},
+		{"negative size flag", []string{"-memory-limit=-1"}, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			p := DefaultPolicy()
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			p.RegisterFlags(fs)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if tt.file != "" {
+				name := filepath.Join(t.TempDir(), "policy.json")
+				if err := os.WriteFile(name, []byte(tt.file), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := p.LoadFile(name, fs); err != nil {
+					t.Fatalf("LoadFile: %v", err)
+				}
+			}
+			if err := p.Validate(); err == nil {
+				t.Errorf("Validate of %+v = nil, want error", p)
+			}
+		})
+	}
+	p := DefaultPolicy()
+	if err := p.Validate(); err != nil {
+		t.Errorf("Validate of DefaultPolicy() = %v, want nil", err)
+	}
+}
+
+// TestPolicyFlags checks that there is a flag for every limit.
+func TestPolicyFlags(t *testing.T) {
+	var p Policy
+	fields := map[any]bool{}
+	for _, f := range policyFlags {
+		fields[f.field(&p)] = true
+	}
+	v := reflect.ValueOf(&p).Elem()
+	for i := range v.NumField() {
+		if !fields[v.Field(i).Addr().Interface()] {
+			t.Errorf("Policy.%s has no flag", v.Type().Field(i).Name)
+		}
+	}
+	if len(fields) != len(policyFlags) {
+		t.Errorf("%d flags set %d distinct Policy fields", len(policyFlags), len(fields))
+	}
+}
@@ -6,11 +6,18 @@
 // to communicate between the different sandbox components.
 package sandboxtypes
 
+import "time"
+
+// ClientHeader is the header of a /run request in which the frontend
+// identifies the client it's running the binary for, such as by IP
+// address. The sandbox uses it to share runs fairly between clients.
+const ClientHeader = "X-Playground-Client"
+
 // Response is the response from the x/playground/sandbox backend to
 // the x/playground frontend.
 //
-// The stdout/stderr are base64 encoded which isn't ideal but is good
-// enough for now. Maybe we'll move to protobufs later.
+// In JSON, the stdout/stderr are base64 encoded. Frontends that
+// accept FramesContentType get them as raw bytes instead.
 type Response struct {
 	// Error, if non-empty, means we failed to run the binary.
 	// It's meant to be user-visible.
@@ -19,4 +26,53 @@ type Response struct {
 	ExitCode int    `json:"exitCode"`
 	Stdout   []byte `json:"stdout"`
 	Stderr   []byte `json:"stderr"`
+
+	// Truncated reports whether the binary's output exceeded the
+	// sandbox's limit. If so, Stdout and Stderr hold only the first
+	// part of each stream and the binary was stopped early.
+	Truncated bool `json:"truncated,omitempty"`
+	// DroppedBytes is the number of bytes of output that were
+	// discarded after the limit was hit, before the binary stopped.
+	DroppedBytes int64 `json:"droppedBytes,omitempty"`
+
+	// OutOfMemory reports whether the binary exceeded the sandbox's
+	// memory limit, Usage.MemoryLimit. If so, it was stopped early.
+	OutOfMemory bool `json:"outOfMemory,omitempty"`
+
+	// Usage, if non-nil, reports the resources the binary used.
+	Usage *Usage `json:"usage,omitempty"`
+
+	// Artifacts are the files the binary wrote to its output
+	// directory, up to the sandbox's limits.
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+	// ArtifactsTruncated reports whether some files in the output
+	// directory were left out of Artifacts because of those limits.
+	ArtifactsTruncated bool `json:"artifactsTruncated,omitempty"`
+}
+
+// ArtifactDir is the directory, relative to its working directory,
+// in which a binary writes files to be returned as Artifacts.
+const ArtifactDir = "out"
+
+// An Artifact is a file written by a binary to ArtifactDir.
+type Artifact struct {
+	Name string `json:"name"` // slash-separated path relative to ArtifactDir
+	Data []byte `json:"data"`
+}
+
+// Usage reports the resources consumed by a single run of a binary,
+// along with the limits it ran under.
+type Usage struct {
+	WallTime   time.Duration `json:"wallTime"`
+	UserTime   time.Duration `json:"userTime"`
+	SystemTime time.Duration `json:"systemTime"`
+	// MaxRSS is the peak resident set size of the program, in bytes.
+	// It is zero if the sandbox could not measure it.
+	MaxRSS int64 `json:"maxRSS"`
+
+	StdoutBytes int64 `json:"stdoutBytes"`
+	StderrBytes int64 `json:"stderrBytes"`
+
+	TimeLimit   time.Duration `json:"timeLimit"`
+	MemoryLimit int64         `json:"memoryLimit"` // in bytes
 }
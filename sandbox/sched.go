@@ -0,0 +1,166 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// errClientBusy is returned by fairScheduler.acquire when a client
+// already has its maximum number of runs in flight.
+var errClientBusy = errors.New("too many runs in flight for client")
+
+// runSched admits /run requests. It's set by main.
+var runSched *fairScheduler
+
+// fairScheduler bounds the number of runs in progress. When they're
+// all busy, it queues requests per client and admits them from each
+// waiting client in turn, so one busy client can't starve the others.
+// It also limits the runs each client has in flight, running or
+// queued, rejecting requests beyond that.
+//
+// Requests that don't name a client, whose client is "", are queued
+// together as one client but aren't limited: they may come from many
+// callers that can't be told apart.
+type fairScheduler struct {
+	capacity  int // maximum runs in progress
+	perClient int // maximum runs in flight per client
+
+	mu      sync.Mutex
+	running int
+	clients map[string]*clientRuns
+	turns   []string // clients with queued runs, in the order they're next admitted
+}
+
+// clientRuns is the state of one client's runs.
+type clientRuns struct {
+	running int
+	queue   []chan struct{} // closed when the run is admitted
+}
+
+// newFairScheduler returns a scheduler that runs up to capacity
+// requests at once and allows perClient in flight per client.
+func newFairScheduler(capacity, perClient int) *fairScheduler {
+	return &fairScheduler{
+		capacity:  capacity,
+		perClient: perClient,
+		clients:   make(map[string]*clientRuns),
+	}
+}
+
+// acquire waits until a run for client may start, and returns a
+// function to call when it's done. It returns errClientBusy if client
+// has too many runs in flight, unless client is "", or ctx's error if
+// ctx is done first.
+func (s *fairScheduler) acquire(ctx context.Context, client string) (release func(), err error) {
+	s.mu.Lock()
+	c := s.clients[client]
+	if c == nil {
+		c = new(clientRuns)
+		s.clients[client] = c
+	}
+	if client != "" && c.running+len(c.queue) >= s.perClient {
+		s.mu.Unlock()
+		return nil, errClientBusy
+	}
+	release = func() { s.release(client) }
+	if s.running < s.capacity && len(s.turns) == 0 {
+		s.running++
+		c.running++
+		s.mu.Unlock()
+		return release, nil
+	}
+	admitted := make(chan struct{})
+	if len(c.queue) == 0 {
+		s.turns = append(s.turns, client)
+	}
+	c.queue = append(c.queue, admitted)
+	s.mu.Unlock()
+
+	select {
+	case <-admitted:
+		return release, nil
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-admitted:
+		// Admitted as ctx was done; give the slot to someone else.
+		s.releaseLocked(client)
+	default:
+		c.queue = slices.DeleteFunc(c.queue, func(ch chan struct{}) bool { return ch == admitted })
+		if len(c.queue) == 0 {
+			s.turns = slices.DeleteFunc(s.turns, func(name string) bool { return name == client })
+		}
+		s.forgetLocked(client)
+	}
+	return nil, ctx.Err()
+}
+
+func (s *fairScheduler) release(client string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releaseLocked(client)
+}
+
+func (s *fairScheduler) releaseLocked(client string) {
+	s.running--
+	s.clients[client].running--
+	s.forgetLocked(client)
+	for s.running < s.capacity && len(s.turns) > 0 {
+		next := s.turns[0]
+		s.turns = s.turns[1:]
+		c := s.clients[next]
+		close(c.queue[0])
+		c.queue = c.queue[1:]
+		if len(c.queue) > 0 {
+			s.turns = append(s.turns, next)
+		}
+		s.running++
+		c.running++
+	}
+}
+
+// forgetLocked removes client's state if it has nothing in flight.
+func (s *fairScheduler) forgetLocked(client string) {
+	if c := s.clients[client]; c.running == 0 && len(c.queue) == 0 {
+		delete(s.clients, client)
+	}
+}
+
+// busy returns the number of runs in progress.
+func (s *fairScheduler) busy() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// queued returns the number of runs waiting to start.
+func (s *fairScheduler) queued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, c := range s.clients {
+		n += len(c.queue)
+	}
+	return n
+}
+
+// clientsInFlight returns the clients with runs in flight, in order.
+func (s *fairScheduler) clientsInFlight() []clientInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var clients []clientInfo
+	for name, c := range s.clients {
+		clients = append(clients, clientInfo{Client: name, Running: c.running, Queued: len(c.queue)})
+	}
+	slices.SortFunc(clients, func(a, b clientInfo) int { return strings.Compare(a.Client, b.Client) })
+	return clients
+}
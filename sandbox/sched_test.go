@@ -0,0 +1,118 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFairSchedulerOrder(t *testing.T) {
+	s := newFairScheduler(1, 3)
+	ctx := t.Context()
+
+	release, err := s.acquire(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Queue two more runs from a, then one from b.
+	admitted := make(chan string, 3)
+	queue := func(client string) {
+		n := s.queued()
+		go func() {
+			release, err := s.acquire(ctx, client)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			admitted <- client
+			time.Sleep(10 * time.Millisecond)
+			release()
+		}()
+		for s.queued() == n {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	queue("a")
+	queue("a")
+	queue("b")
+	if _, err := s.acquire(ctx, "a"); !errors.Is(err, errClientBusy) {
+		t.Errorf("acquire with 3 runs in flight = %v; want errClientBusy", err)
+	}
+
+	release()
+	var order []string
+	for range 3 {
+		order = append(order, <-admitted)
+	}
+	if got, want := order, []string{"a", "b", "a"}; !slices.Equal(got, want) {
+		t.Errorf("runs admitted in order %q; want %q", got, want)
+	}
+}
+
+func TestFairSchedulerCancel(t *testing.T) {
+	s := newFairScheduler(1, 2)
+	release, err := s.acquire(t.Context(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.acquire(ctx, "b"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("acquire after timeout = %v; want context.DeadlineExceeded", err)
+	}
+	if n := s.queued(); n != 0 {
+		t.Errorf("after canceled acquire, %d queued; want 0", n)
+	}
+
+	release()
+	if n := s.busy(); n != 0 {
+		t.Errorf("after release, %d busy; want 0", n)
+	}
+	if len(s.clients) != 0 || len(s.turns) != 0 {
+		t.Errorf("after release, scheduler has clients %v and turns %q; want none", s.clients, s.turns)
+	}
+}
+
+func TestFairSchedulerNoClient(t *testing.T) {
+	s := newFairScheduler(1, 1)
+	release, err := s.acquire(t.Context(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests without a client header aren't limited per client, so
+	// a second one waits for a slot instead of being rejected.
+	admitted := make(chan error, 1)
+	go func() {
+		release, err := s.acquire(t.Context(), "")
+		if err == nil {
+			release()
+		}
+		admitted <- err
+	}()
+	for s.queued() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	release()
+	if err := <-admitted; err != nil {
+		t.Errorf("second acquire without a client = %v; want nil", err)
+	}
+
+	// A named client is still limited.
+	release, err = s.acquire(t.Context(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	if _, err := s.acquire(t.Context(), "a"); !errors.Is(err, errClientBusy) {
+		t.Errorf("acquire with 1 run in flight = %v; want errClientBusy", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// deniedSyscalls are the system calls that fail with EPERM under the
+// seccomp filter. They're the ones that administer the machine or
+// reach outside the process's own namespaces.
+var deniedSyscalls = []uint32{
+	unix.SYS_ACCT,
+	unix.SYS_ADD_KEY,
+	unix.SYS_ADJTIMEX,
+	unix.SYS_BPF,
+	unix.SYS_CHROOT,
+	unix.SYS_CLOCK_ADJTIME,
+	unix.SYS_CLOCK_SETTIME,
+	unix.SYS_DELETE_MODULE,
+	unix.SYS_FINIT_MODULE,
+	unix.SYS_INIT_MODULE,
+	unix.SYS_IOPERM,
+	unix.SYS_IOPL,
+	unix.SYS_KEXEC_FILE_LOAD,
+	unix.SYS_KEXEC_LOAD,
+	unix.SYS_KEYCTL,
+	unix.SYS_MOUNT,
+	unix.SYS_NAME_TO_HANDLE_AT,
+	unix.SYS_OPEN_BY_HANDLE_AT,
+	unix.SYS_PERF_EVENT_OPEN,
+	unix.SYS_PIVOT_ROOT,
+	unix.SYS_PROCESS_VM_READV,
+	unix.SYS_PROCESS_VM_WRITEV,
+	unix.SYS_PTRACE,
+	unix.SYS_QUOTACTL,
+	unix.SYS_REBOOT,
+	unix.SYS_REQUEST_KEY,
+	unix.SYS_SETDOMAINNAME,
+	unix.SYS_SETHOSTNAME,
+	unix.SYS_SETNS,
+	unix.SYS_SETTIMEOFDAY,
+	unix.SYS_SWAPOFF,
+	unix.SYS_SWAPON,
+	unix.SYS_UMOUNT2,
+	unix.SYS_UNSHARE,
+	unix.SYS_USERFAULTFD,
+}
+
+// namespaceCloneFlags are the clone flags that create new namespaces.
+const namespaceCloneFlags = unix.CLONE_NEWUSER | unix.CLONE_NEWNS | unix.CLONE_NEWPID |
+	unix.CLONE_NEWNET | unix.CLONE_NEWIPC | unix.CLONE_NEWUTS | unix.CLONE_NEWCGROUP
+
+// x32SyscallBit is set in the numbers of x32 ABI system calls.
+const x32SyscallBit = 0x40000000
+
+// Offsets of the fields of struct seccomp_data.
+const (
+	seccompDataNr   = 0
+	seccompDataArch = 4
+	seccompDataArg0 = 16 // low 32 bits, as amd64 is little-endian
+)
+
+// seccompFilter returns the BPF program installed by installSeccomp.
+func seccompFilter() []unix.SockFilter {
+	stmt := func(code uint16, k uint32) unix.SockFilter {
+		return unix.SockFilter{Code: code, K: k}
+	}
+	jump := func(code uint16, k uint32, jt, jf uint8) unix.SockFilter {
+		return unix.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+	}
+	const (
+		load  = unix.BPF_LD | unix.BPF_W | unix.BPF_ABS
+		ret   = unix.BPF_RET | unix.BPF_K
+		jeq   = unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K
+		jge   = unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K
+		jset  = unix.BPF_JMP | unix.BPF_JSET | unix.BPF_K
+		allow = unix.SECCOMP_RET_ALLOW
+		eperm = unix.SECCOMP_RET_ERRNO | uint32(unix.EPERM)
+		// ENOSYS makes callers fall back to an older system call.
+		enosys = unix.SECCOMP_RET_ERRNO | uint32(unix.ENOSYS)
+	)
+
+	f := []unix.SockFilter{
+		// Only native amd64 system calls are allowed; the x32 and
+		// i386 ABIs number them differently.
+		stmt(load, seccompDataArch),
+		jump(jeq, unix.AUDIT_ARCH_X86_64, 1, 0),
+		stmt(ret, unix.SECCOMP_RET_KILL_PROCESS),
+		stmt(load, seccompDataNr),
+		jump(jge, x32SyscallBit, 0, 1),
+		stmt(ret, enosys),
+	}
+	for _, nr := range deniedSyscalls {
+		f = append(f, jump(jeq, nr, 0, 1), stmt(ret, eperm))
+	}
+	// clone3 passes its flags in memory, where seccomp can't see
+	// them, so deny it and make callers use clone.
+	f = append(f, jump(jeq, unix.SYS_CLONE3, 0, 1), stmt(ret, enosys))
+	f = append(f,
+		jump(jeq, unix.SYS_CLONE, 0, 3),
+		stmt(load, seccompDataArg0),
+		jump(jset, namespaceCloneFlags, 0, 1),
+		stmt(ret, eperm),
+		stmt(ret, allow),
+	)
+	return f
+}
+
+// installSeccomp installs seccompFilter on all threads of this
+// process. The caller must have set PR_SET_NO_NEW_PRIVS.
+func installSeccomp() error {
+	filter := seccompFilter()
+	prog := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	_, _, errno := unix.Syscall(unix.SYS_SECCOMP, unix.SECCOMP_SET_MODE_FILTER,
+		unix.SECCOMP_FILTER_FLAG_TSYNC, uintptr(unsafe.Pointer(&prog)))
+	if errno != 0 {
+		return fmt.Errorf("seccomp: %v", errno)
+	}
+	return nil
+}
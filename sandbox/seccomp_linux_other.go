@@ -0,0 +1,16 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux && !amd64
+
+package main
+
+import (
+	"errors"
+	"runtime"
+)
+
+func installSeccomp() error {
+	return errors.New("no seccomp filter for " + runtime.GOARCH)
+}
@@ -0,0 +1,63 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// draining is set when the sandbox starts shutting down. From then
+// on it fails readiness checks, so frontends stop sending it runs.
+var draining atomic.Bool
+
+// shutdownSlack is how long, beyond the longest a run may take, the
+// sandbox waits for in-flight requests to finish when shutting down.
+// It covers waiting for a container and sending the response.
+const shutdownSlack = 10 * time.Second
+
+// handleSignals waits for SIGINT or SIGTERM and then shuts down
+// gracefully: see shutdown. stop is called to stop the container
+// pool and other background work. done is closed once the sandbox
+// has shut down. A second signal exits immediately.
+func handleSignals(stop context.CancelFunc, done chan<- struct{}) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
+	s := <-c
+	log.Printf("shutting down on signal %d: %v", s, s)
+	go func() {
+		s := <-c
+		log.Fatalf("closing on second signal %d: %v", s, s)
+	}()
+	shutdown(stop)
+	close(done)
+}
+
+// shutdown drains the sandbox. It fails readiness checks for
+// --drain-delay, so frontends notice and stop sending requests, then
+// stops accepting connections and waits for in-flight runs to
+// finish. Finally it stops the pool, closing its warm containers.
+func shutdown(stop context.CancelFunc) {
+	draining.Store(true)
+	if *drainDelay > 0 {
+		log.Printf("draining for %v before closing the listener", *drainDelay)
+		time.Sleep(*drainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), policy.MaxRunTime+shutdownSlack)
+	defer cancel()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		log.Printf("error waiting for in-flight requests: %v", err)
+	}
+
+	stop()
+	pool.wait()
+	log.Printf("shutdown complete")
+}
@@ -0,0 +1,76 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestShutdown(t *testing.T) {
+	oldServer, oldDelay, oldRunSched := httpServer, *drainDelay, runSched
+	defer func() {
+		httpServer, *drainDelay, runSched = oldServer, oldDelay, oldRunSched
+		draining.Store(false)
+	}()
+	*drainDelay = 0
+	runSched = newFairScheduler(1, 1)
+
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/run", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+	mux.HandleFunc("/readyz", readinessHandler)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpServer = &http.Server{Handler: mux}
+	go httpServer.Serve(ln)
+	url := "http://" + ln.Addr().String()
+
+	// Start a run, then shut down while it's in flight.
+	type result struct {
+		body string
+		err  error
+	}
+	runDone := make(chan result, 1)
+	go func() {
+		res, err := http.Post(url+"/run", "", nil)
+		if err != nil {
+			runDone <- result{err: err}
+			return
+		}
+		defer res.Body.Close()
+		b, err := io.ReadAll(res.Body)
+		runDone <- result{string(b), err}
+	}()
+	<-started
+
+	stopped := false
+	shutdown(func() { stopped = true })
+	if r := <-runDone; r.err != nil || r.body != "done" {
+		t.Errorf("in-flight run = %q, %v; want it to finish", r.body, r.err)
+	}
+	if !stopped {
+		t.Errorf("shutdown didn't stop the pool")
+	}
+	if _, err := http.Get(url + "/readyz"); err == nil {
+		t.Errorf("after shutdown, server still accepts connections")
+	}
+
+	w := httptest.NewRecorder()
+	readinessHandler(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("while draining, /readyz status = %d; want %d", w.Code, http.StatusServiceUnavailable)
+	}
+}
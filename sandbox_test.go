@@ -5,15 +5,18 @@
 package main
 
 import (
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 
 	"go.opencensus.io/stats/view"
+	"golang.org/x/playground/sandbox/sandboxtypes"
 )
 
 // TestExperiments tests that experiment lines are recognized.
@@ -142,7 +145,7 @@ func TestSandboxRunMetrics(t *testing.T) {
 	tmpFile.Close()
 
 	ctx := t.Context()
-	if _, err = sandboxRun(ctx, tmpFile.Name(), ""); err != nil {
+	if _, err = sandboxRun(ctx, tmpFile.Name(), "", nil); err != nil {
 		t.Fatalf("sandboxRun failed: %v", err)
 	}
 
@@ -170,3 +173,90 @@ func TestSandboxRunMetrics(t *testing.T) {
 		t.Errorf("metric go-playground/frontend/go_run_count with tag go_run_success=success was not recorded. Rows: %v", rows)
 	}
 }
+
+func TestIsDataFile(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		want bool
+	}{
+		{"prog.go", false},
+		{"go.mod", false},
+		{"go.sum", false},
+		{"go.work", false},
+		{"go.work.sum", false},
+		{"foo/bar.go", false},
+		{"asm_amd64.s", false},
+		{"foo/hello.c", false},
+		{"foo/hello.h", false},
+		{"rsrc.syso", false},
+		{"testdata/x.s", true},
+		{"input.txt", true},
+		{"foo/go.mod", true},
+		{"testdata/golden.go", true},
+		{"foo/testdata/x.go", true},
+		{"mytestdata/x.go", false},
+	} {
+		if got := isDataFile(tt.name); got != tt.want {
+			t.Errorf("isDataFile(%q) = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClientAddr(t *testing.T) {
+	for _, tt := range []struct {
+		remoteAddr string
+		xff        string
+		want       string
+	}{
+		{"192.0.2.1:1234", "", "192.0.2.1"},
+		{"[2001:db8::1]:1234", "", "2001:db8::1"},
+		{"10.0.0.1:1234", "198.51.100.7, 10.0.0.2", "198.51.100.7"},
+		{"10.0.0.1:1234", "203.0.113.9, 198.51.100.7, 10.0.0.2", "198.51.100.7"},
+		{"10.0.0.1:1234", "198.51.100.7", "198.51.100.7"},
+	} {
+		r := httptest.NewRequest("POST", "/compile", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.xff != "" {
+			r.Header.Set("X-Forwarded-For", tt.xff)
+		}
+		if got := clientAddr(r); got != tt.want {
+			t.Errorf("clientAddr(RemoteAddr %q, X-Forwarded-For %q) = %q; want %q", tt.remoteAddr, tt.xff, got, tt.want)
+		}
+	}
+}
+
+func TestSandboxRunFramed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !sandboxtypes.IsFramesContentType(r.Header.Get("Content-Type")) || !sandboxtypes.AcceptsFrames(r.Header.Get("Accept")) {
+			http.Error(w, "want framed request", http.StatusUnsupportedMediaType)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		rr, err := sandboxtypes.UnmarshalRunRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		res := &sandboxtypes.Response{
+			Stdout: append(rr.Binary, rr.Files["testdata/in.txt"]...),
+			Stderr: []byte(strings.Join(rr.Args, " ")),
+		}
+		b, _ := res.MarshalFrames()
+		w.Header().Set("Content-Type", sandboxtypes.FramesContentType)
+		w.Write(b)
+	}))
+	defer server.Close()
+	t.Setenv("SANDBOX_BACKEND_URL", server.URL)
+
+	exe := filepath.Join(t.TempDir(), "a.out")
+	if err := os.WriteFile(exe, []byte("binary;"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	res, err := sandboxRun(t.Context(), exe, "-test.v", map[string][]byte{"testdata/in.txt": []byte("data")})
+	if err != nil {
+		t.Fatalf("sandboxRun: %v", err)
+	}
+	if string(res.Stdout) != "binary;data" || string(res.Stderr) != "-test.v" {
+		t.Errorf("sandboxRun = stdout %q, stderr %q; want %q, %q", res.Stdout, res.Stderr, "binary;data", "-test.v")
+	}
+}
@@ -7,25 +7,32 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"golang.org/x/playground/internal/snippets"
 	"golang.org/x/tools/godoc/static"
 )
 
 type server struct {
 	mux      *http.ServeMux
-	db       store
+	db       snippets.Store
 	log      logger
 	cache    responseCache
 	gotip    bool // if set, server is using gotip
+	goprev   bool // if set, server is using the previous Go release
 	examples *examplesHandler
 
 	// When the executable was last modified. Used for caching headers of compiled assets.
 	modtime time.Time
+
+	// adminKeys maps the names of admins to their keys for the admin
+	// API. If nil, the admin API is disabled.
+	adminKeys map[string][]byte
 }
 
 func newServer(options ...func(s *server) error) (*server, error) {
@@ -52,9 +59,13 @@ func (s *server) init() {
 	s.mux.HandleFunc("/", s.handleEdit)
 	s.mux.HandleFunc("/fmt", s.handleFmt)
 	s.mux.HandleFunc("/version", s.handleVersion)
-	s.mux.HandleFunc("/vet", s.commandHandler("vet", vetCheck))
-	s.mux.HandleFunc("/compile", s.commandHandler("prog", compileAndRun))
+	s.mux.HandleFunc("/vet", s.commandHandler("vet", false, vetCheck))
+	s.mux.HandleFunc("/compile", s.commandHandler("prog", true, compileAndRun))
 	s.mux.HandleFunc("/share", s.handleShare)
+	s.mux.HandleFunc("/lineage/", s.handleLineage)
+	s.mux.HandleFunc("/m/", s.handleMutable)
+	s.mux.HandleFunc("/admin/remove", s.handleAdminRemove)
+	s.mux.HandleFunc("/admin/removals", s.handleAdminRemovals)
 	s.mux.HandleFunc("/playground.js", s.handlePlaygroundJS)
 	s.mux.HandleFunc("/favicon.ico", handleFavicon)
 	s.mux.HandleFunc("/_ah/health", s.handleHealthCheck)
@@ -64,6 +75,18 @@ func (s *server) init() {
 	s.mux.Handle("/doc/play/", http.StripPrefix("/doc/play/", s.examples))
 }
 
+// toolchain returns the name of the Go toolchain the server uses, as
+// recorded in shared snippets: "gotip", "goprev" or "stable".
+func (s *server) toolchain() string {
+	switch {
+	case s.gotip:
+		return "gotip"
+	case s.goprev:
+		return "goprev"
+	}
+	return "stable"
+}
+
 func (s *server) handlePlaygroundJS(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-type", "text/javascript; charset=utf-8")
 	rd := strings.NewReader(static.Files["playground.js"])
@@ -95,6 +118,13 @@ func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	s.mux.ServeHTTP(w, r)
 }
 
+// isTooLarge reports whether err is the result of reading past the
+// limit of an http.MaxBytesReader.
+func isTooLarge(err error) bool {
+	var mbe *http.MaxBytesError
+	return errors.As(err, &mbe)
+}
+
 // writeJSONResponse JSON-encodes resp and writes to w with the given HTTP
 // status.
 func (s *server) writeJSONResponse(w http.ResponseWriter, resp any, status int) {
@@ -14,12 +14,14 @@ import (
 	"net/http/httptest"
 	"os"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/bradfitz/gomemcache/memcache"
 	"github.com/google/go-cmp/cmp"
+	"golang.org/x/playground/internal/snippets"
 )
 
 type testLogger struct {
@@ -38,7 +40,7 @@ func (l testLogger) Fatalf(format string, args ...any) {
 
 func testingOptions(t *testing.T) func(s *server) error {
 	return func(s *server) error {
-		s.db = &inMemStore{}
+		s.db = &snippets.MemStore{}
 		s.log = testLogger{t}
 		var err error
 		s.examples, err = newExamplesHandler(false, time.Now())
@@ -56,10 +58,15 @@ func TestEdit(t *testing.T) {
 	}
 	id := "bar"
 	barBody := []byte("Snippy McSnipface")
-	snip := &snippet{Body: barBody}
+	snip := &snippets.Snippet{Body: barBody}
 	if err := s.db.PutSnippet(context.Background(), id, snip); err != nil {
 		t.Fatalf("s.dbPutSnippet(context.Background(), %+v, %+v): %v", id, snip, err)
 	}
+	created := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	metaSnip := &snippets.Snippet{Body: []byte("-- a.go --\n-- b.go --\n"), Created: created, Toolchain: "gotip", Size: 22, NumFiles: 2}
+	if err := s.db.PutSnippet(context.Background(), "meta", metaSnip); err != nil {
+		t.Fatalf("s.dbPutSnippet(context.Background(), %+v, %+v): %v", "meta", metaSnip, err)
+	}
 
 	testCases := []struct {
 		desc       string
@@ -76,6 +83,11 @@ func TestEdit(t *testing.T) {
 		{"Existing snippet", http.MethodGet, "https://play.golang.org/p/" + id, http.StatusFound, nil, nil},
 		{"Plaintext snippet", http.MethodGet, "https://play.golang.org/p/" + id + ".go", http.StatusOK, nil, barBody},
 		{"Download snippet", http.MethodGet, "https://play.golang.org/p/" + id + ".go?download=true", http.StatusOK, map[string]string{"Content-Disposition": fmt.Sprintf(`attachment; filename="%s.go"`, id)}, barBody},
+		{"Snippet metadata", http.MethodGet, "https://play.golang.org/p/meta.json", http.StatusOK, map[string]string{"Content-Type": "application/json"},
+			[]byte(`{"ID":"meta","Created":"2026-10-01T12:00:00Z","Toolchain":"gotip","Size":22,"NumFiles":2}` + "\n")},
+		{"Metadata of snippet shared without it", http.MethodGet, "https://play.golang.org/p/" + id + ".json", http.StatusOK, nil,
+			[]byte(`{"ID":"bar","Size":17,"NumFiles":1}` + "\n")},
+		{"Metadata of unknown snippet", http.MethodGet, "https://play.golang.org/p/foo.json", http.StatusNotFound, nil, nil},
 	}
 
 	for _, tc := range testCases {
@@ -127,7 +139,7 @@ func TestServer(t *testing.T) {
 		{"OPTIONS no-op", http.MethodOptions, shareURL, http.StatusOK, nil, nil},
 		{"Non-POST request", http.MethodGet, shareURL, http.StatusMethodNotAllowed, nil, nil},
 		{"Standard flow", http.MethodPost, shareURL, http.StatusOK, []byte("Snippy McSnipface"), []byte("N_M_YelfGeR")},
-		{"Snippet too large", http.MethodPost, shareURL, http.StatusRequestEntityTooLarge, make([]byte, maxSnippetSize+1), nil},
+		{"Snippet too large", http.MethodPost, shareURL, http.StatusRequestEntityTooLarge, make([]byte, policy.MaxSnippetSize+1), nil},
 
 		// Examples tests.
 		{"Hello example", http.MethodGet, "https://play.golang.org/doc/play/hello.txt", http.StatusOK, nil, []byte("Hello")},
@@ -163,6 +175,48 @@ func TestServer(t *testing.T) {
 	}
 }
 
+func TestShareMetadata(t *testing.T) {
+	s, err := newServer(testingOptions(t))
+	if err != nil {
+		t.Fatalf("newServer(testingOptions(t)): %v", err)
+	}
+	s.goprev = true
+	body := "-- go.mod --\nmodule m\n-- main.go --\npackage main\n"
+	share := func() string {
+		w := httptest.NewRecorder()
+		s.handleShare(w, httptest.NewRequest("POST", "/share", strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("POST /share: status %d", w.Code)
+		}
+		return w.Body.String()
+	}
+
+	before := time.Now()
+	id := share()
+	var first snippets.Snippet
+	if err := s.db.GetSnippet(context.Background(), id, &first); err != nil {
+		t.Fatalf("GetSnippet(%q): %v", id, err)
+	}
+	if first.Created.Before(before.Add(-time.Second)) || first.Created.After(time.Now()) {
+		t.Errorf("Created = %v; want about %v", first.Created, before)
+	}
+	if first.Toolchain != "goprev" || first.Size != len(body) || first.NumFiles != 2 {
+		t.Errorf("Toolchain, Size, NumFiles = %q, %d, %d; want %q, %d, %d", first.Toolchain, first.Size, first.NumFiles, "goprev", len(body), 2)
+	}
+
+	// Sharing the same body again from another toolchain keeps
+	// the original metadata.
+	s.goprev, s.gotip = false, true
+	share()
+	var again snippets.Snippet
+	if err := s.db.GetSnippet(context.Background(), id, &again); err != nil {
+		t.Fatalf("GetSnippet(%q): %v", id, err)
+	}
+	if !again.Created.Equal(first.Created) || again.Toolchain != "goprev" {
+		t.Errorf("after sharing again, Created, Toolchain = %v, %q; want %v, %q", again.Created, again.Toolchain, first.Created, "goprev")
+	}
+}
+
 func TestNoTrailingUnderscore(t *testing.T) {
 	const trailingUnderscoreSnip = `package main
 
@@ -175,7 +229,7 @@ func (T) m2([unsafe.Sizeof(T.m1)]int) {}
 
 func main() {}
 `
-	snip := &snippet{[]byte(trailingUnderscoreSnip)}
+	snip := &snippets.Snippet{Body: []byte(trailingUnderscoreSnip)}
 	if got, want := snip.ID(), "WCktUidLyc_3"; got != want {
 		t.Errorf("got %q; want %q", got, want)
 	}
@@ -183,7 +237,7 @@ func main() {}
 
 func TestCommandHandler(t *testing.T) {
 	s, err := newServer(func(s *server) error {
-		s.db = &inMemStore{}
+		s.db = &snippets.MemStore{}
 		// testLogger makes tests fail.
 		// Should we verify that s.log.Errorf was called
 		// instead of just printing or failing the test?
@@ -199,7 +253,7 @@ func TestCommandHandler(t *testing.T) {
 	if err != nil {
 		t.Fatalf("newServer(testingOptions(t)): %v", err)
 	}
-	testHandler := s.commandHandler("test", func(_ context.Context, r *request) (*response, error) {
+	testHandler := s.commandHandler("test", true, func(_ context.Context, r *request) (*response, error) {
 		if r.Body == "fail" {
 			return nil, fmt.Errorf("non recoverable")
 		}
@@ -221,12 +275,18 @@ func TestCommandHandler(t *testing.T) {
 		if r.Body == "allocate-memory-compile-error" {
 			return &response{Errors: "cannot allocate memory"}, nil
 		}
+		if r.Body == "program-oom" {
+			return &response{Events: []Event{{"fatal error: runtime: out of memory\n", "stderr", 0}}, Status: 2, OutOfMemory: true}, nil
+		}
 		if r.Body == "build-timeout-error" {
 			return &response{Errors: goBuildTimeoutError}, nil
 		}
 		if r.Body == "run-timeout-error" {
 			return &response{Errors: runTimeoutError}, nil
 		}
+		if r.Body == "run-busy-error" {
+			return &response{Errors: runBusyError}, nil
+		}
 		resp := &response{Events: []Event{{r.Body, "stdout", 0}}}
 		return resp, nil
 	})
@@ -243,6 +303,7 @@ func TestCommandHandler(t *testing.T) {
 		{"GET request", http.MethodGet, http.StatusBadRequest, nil, nil, false},
 		{"Empty POST", http.MethodPost, http.StatusBadRequest, nil, nil, false},
 		{"Failed cmdFunc", http.MethodPost, http.StatusInternalServerError, []byte(`{"Body":"fail"}`), nil, false},
+		{"Request too large", http.MethodPost, http.StatusRequestEntityTooLarge, []byte(`{"Body":"` + strings.Repeat("x", int(policy.MaxRequestSize)) + `"}`), nil, false},
 		{"Standard flow", http.MethodPost, http.StatusOK,
 			[]byte(`{"Body":"ok"}`),
 			[]byte(`{"Errors":"","Events":[{"Message":"ok","Kind":"stdout","Delay":0}],"Status":0,"IsTest":false,"TestsFailed":0}
@@ -261,6 +322,22 @@ func TestCommandHandler(t *testing.T) {
 			[]byte(`{"Body":"oom-compile-error"}`), nil, false},
 		{"Cannot allocate memory error in response errors", http.MethodPost, http.StatusInternalServerError,
 			[]byte(`{"Body":"allocate-memory-compile-error"}`), nil, false},
+		{
+			desc:       "Repeated runs",
+			method:     http.MethodPost,
+			statusCode: http.StatusOK,
+			reqBody:    []byte(`{"Body":"runs","Runs":3}`),
+			respBody:   []byte(fmt.Sprintln(`{"Errors":"","Events":[{"Message":"runs","Kind":"stdout","Delay":0}],"Status":0,"IsTest":false,"TestsFailed":0}`)),
+		},
+		{"Too many runs", http.MethodPost, http.StatusBadRequest,
+			[]byte(`{"Body":"too-many-runs","Runs":1000}`), nil, false},
+		{
+			desc:       "Program out of memory",
+			method:     http.MethodPost,
+			statusCode: http.StatusOK,
+			reqBody:    []byte(`{"Body":"program-oom"}`),
+			respBody:   []byte(fmt.Sprintln(`{"Errors":"","Events":[{"Message":"fatal error: runtime: out of memory\n","Kind":"stderr","Delay":0}],"Status":2,"IsTest":false,"TestsFailed":0,"OutOfMemory":true}`)),
+		},
 		{
 			desc:       "Build timeout error",
 			method:     http.MethodPost,
@@ -275,6 +352,13 @@ func TestCommandHandler(t *testing.T) {
 			reqBody:    []byte(`{"Body":"run-timeout-error"}`),
 			respBody:   []byte(fmt.Sprintln(`{"Errors":"timeout running program","Events":null,"Status":0,"IsTest":false,"TestsFailed":0}`)),
 		},
+		{
+			desc:       "Run busy error",
+			method:     http.MethodPost,
+			statusCode: http.StatusOK,
+			reqBody:    []byte(`{"Body":"run-busy-error"}`),
+			respBody:   []byte(fmt.Sprintln(`{"Errors":"` + runBusyError + `","Events":null,"Status":0,"IsTest":false,"TestsFailed":0}`)),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -321,6 +405,31 @@ func TestCommandHandler(t *testing.T) {
 	}
 }
 
+func TestCommandHandlerRuns(t *testing.T) {
+	s, err := newServer(testingOptions(t))
+	if err != nil {
+		t.Fatalf("newServer(testingOptions(t)): %v", err)
+	}
+	s.cache = new(inMemCache)
+	handler := s.commandHandler("vet", false, func(context.Context, *request) (*response, error) {
+		return &response{}, nil
+	})
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"Body":"x"}`, http.StatusOK},
+		{`{"Body":"x","Runs":1}`, http.StatusOK},
+		{`{"Body":"x","Runs":2}`, http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("POST", "/vet", strings.NewReader(tc.body)))
+		if w.Code != tc.want {
+			t.Errorf("POST /vet %s: status %d; want %d", tc.body, w.Code, tc.want)
+		}
+	}
+}
+
 func TestPlaygroundGoproxy(t *testing.T) {
 	const envKey = "PLAY_GOPROXY"
 	defer os.Setenv(envKey, os.Getenv(envKey))
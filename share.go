@@ -6,40 +6,40 @@ package main
 
 import (
 	"bytes"
-	"crypto/sha256"
-	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-)
-
-const (
-	// This salt is not meant to be kept secret (it’s checked in after all). It’s
-	// a tiny bit of paranoia to avoid whatever problems a collision may cause.
-	salt = "Go playground salt\n"
+	"time"
 
-	maxSnippetSize = 64 * 1024
+	"cloud.google.com/go/datastore"
+	"golang.org/x/playground/internal/snippets"
 )
 
-type snippet struct {
-	Body []byte `datastore:",noindex"` // golang.org/issues/23253
+// snippetMeta is the JSON served by /p/ID.json.
+type snippetMeta struct {
+	ID        string
+	Created   time.Time `json:",omitzero"`
+	Toolchain string    `json:",omitempty"`
+	Size      int
+	NumFiles  int
+	Parent    string `json:",omitempty"`
+	Removed   bool   `json:",omitempty"`
 }
 
-func (s *snippet) ID() string {
-	h := sha256.New()
-	io.WriteString(h, salt)
-	h.Write(s.Body)
-	sum := h.Sum(nil)
-	b := make([]byte, base64.URLEncoding.EncodedLen(len(sum)))
-	base64.URLEncoding.Encode(b, sum)
-	// Web sites don’t always linkify a trailing underscore, making it seem like
-	// the link is broken. If there is an underscore at the end of the substring,
-	// extend it until there is not.
-	hashLen := 11
-	for hashLen <= len(b) && b[hashLen-1] == '_' {
-		hashLen++
+// newSnippetMeta returns the metadata of s, which has the given id.
+// Size and NumFiles are computed for snippets that didn't record them.
+// Only the ID of a removed snippet is given.
+func newSnippetMeta(id string, s *snippets.Snippet) *snippetMeta {
+	if !s.Removed.IsZero() {
+		return &snippetMeta{ID: id, Removed: true}
 	}
-	return string(b)[:hashLen]
+	m := &snippetMeta{ID: id, Created: s.Created, Toolchain: s.Toolchain, Size: s.Size, NumFiles: s.NumFiles, Parent: s.Parent}
+	if s.Created.IsZero() {
+		m.Size = len(s.Body)
+		m.NumFiles = snippets.NumFiles(s.Body)
+	}
+	return m
 }
 
 func (s *server) handleShare(w http.ResponseWriter, r *http.Request) {
@@ -53,26 +53,63 @@ func (s *server) handleShare(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The parent is in the URL, not the form, as the body is the
+	// snippet.
+	parent := r.URL.Query().Get("parent")
+	if parent != "" {
+		if !snippets.ValidID(parent) {
+			http.Error(w, "Invalid parent snippet ID", http.StatusBadRequest)
+			return
+		}
+		if err := s.db.GetSnippet(r.Context(), parent, new(snippets.Snippet)); err != nil {
+			if err == datastore.ErrNoSuchEntity {
+				http.Error(w, "Parent snippet not found", http.StatusBadRequest)
+				return
+			}
+			s.log.Errorf("loading Snippet: %v", err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	id, ok := s.shareSnippet(w, r, parent)
+	if !ok {
+		return
+	}
+	fmt.Fprint(w, id)
+}
+
+// shareSnippet stores the snippet in the body of r, a fork of the
+// snippet parent, which may be empty, and returns its ID. If it
+// fails, it replies with an error and returns false.
+func (s *server) shareSnippet(w http.ResponseWriter, r *http.Request, parent string) (string, bool) {
 	var body bytes.Buffer
-	_, err := io.Copy(&body, io.LimitReader(r.Body, maxSnippetSize+1))
+	_, err := io.Copy(&body, io.LimitReader(r.Body, policy.MaxSnippetSize+1))
 	r.Body.Close()
 	if err != nil {
 		s.log.Errorf("reading Body: %v", err)
 		http.Error(w, "Server Error", http.StatusInternalServerError)
-		return
+		return "", false
 	}
-	if body.Len() > maxSnippetSize {
+	if int64(body.Len()) > policy.MaxSnippetSize {
 		http.Error(w, "Snippet is too large", http.StatusRequestEntityTooLarge)
-		return
+		return "", false
 	}
 
-	snip := &snippet{Body: body.Bytes()}
+	snip := snippets.New(body.Bytes(), s.toolchain(), parent)
 	id := snip.ID()
+	if id == parent {
+		// Shared again without changes.
+		snip.Parent = ""
+	}
 	if err := s.db.PutSnippet(r.Context(), id, snip); err != nil {
+		if errors.Is(err, snippets.ErrRemoved) {
+			http.Error(w, "Snippet was removed and can't be shared", http.StatusGone)
+			return "", false
+		}
 		s.log.Errorf("putting Snippet: %v", err)
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
-		return
+		return "", false
 	}
-
-	fmt.Fprint(w, id)
+	return id, true
 }
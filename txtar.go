@@ -108,13 +108,12 @@ func splitFiles(src []byte) (*fileSet, error) {
 		fs.noHeader = true
 		fs.AddFile(progName, a.Comment)
 	}
-	const limitNumFiles = 20 // arbitrary
 	numFiles := len(a.Files) + fs.Num()
-	if numFiles > limitNumFiles {
-		return nil, fmt.Errorf("too many files in txtar archive (%v exceeds limit of %v)", numFiles, limitNumFiles)
+	if numFiles > policy.MaxFiles {
+		return nil, fmt.Errorf("too many files in txtar archive (%v exceeds limit of %v)", numFiles, policy.MaxFiles)
 	}
 	for _, f := range a.Files {
-		if len(f.Name) > 200 { // arbitrary limit
+		if len(f.Name) > policy.MaxFileNameLen {
 			return nil, errors.New("file name too long")
 		}
 		if strings.IndexFunc(f.Name, isBogusFilenameRune) != -1 {
@@ -124,7 +123,7 @@ func splitFiles(src []byte) (*fileSet, error) {
 			return nil, fmt.Errorf("invalid file name %q", f.Name)
 		}
 		parts := strings.Split(f.Name, "/")
-		if len(parts) > 10 { // arbitrary limit
+		if len(parts) > policy.MaxFileDepth {
 			return nil, fmt.Errorf("file name %q too deep", f.Name)
 		}
 		for _, part := range parts {
@@ -25,7 +25,16 @@ import (
 // the /compile (compileAndRun) handler instead with the WithVet
 // boolean set. This code path doesn't support modules and only exists
 // as a temporary compatibility bridge to older javascript clients.
+//
+// If there's a build service, vet runs there.
 func vetCheck(ctx context.Context, req *request) (*response, error) {
+	if *buildURL != "" {
+		vetOutput, err := remoteVet(ctx, *buildURL, []byte(req.Body))
+		if err != nil {
+			return nil, err
+		}
+		return &response{Errors: vetOutput}, nil
+	}
 	tmpDir, err := os.MkdirTemp("", "vet")
 	if err != nil {
 		return nil, fmt.Errorf("error creating temp directory: %v", err)
@@ -68,10 +77,8 @@ func vetCheckInDir(ctx context.Context, dir, goPath string, experiments []string
 	// Prevent vet from compiling packages in cgo mode.
 	// See #26307.
 	cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOPATH="+goPath)
-	cmd.Env = append(cmd.Env,
-		"GO111MODULE=on",
-		"GOPROXY="+playgroundGoproxy(),
-	)
+	cmd.Env = append(cmd.Env, "GO111MODULE=on")
+	cmd.Env = append(cmd.Env, goproxyEnv()...)
 	if len(experiments) > 0 {
 		cmd.Env = append(cmd.Env, "GOEXPERIMENT="+strings.Join(experiments, ","))
 	}